@@ -0,0 +1,490 @@
+// Package asm assembles CHIP-8 programs written in the emulator mnemonics
+// (the ones ProcessCmd prints and chip8-disasm lists), i.e. "MOV V1, 0a" or "DRAW 5, V1, V2".
+//
+// Source syntax:
+//
+//	; comment till the end of line
+//	loop:               label, could be followed by instruction on the same line
+//	SPEED = 0a          constant, value could use labels and other constants
+//	include "file.asm"  relative file path is taken from the including file directory
+//	db 0xf0, 0x90, 1    bytes
+//	dw 0x1234, loop     big endian words
+//
+// Numbers are hex as the emulator prints them ("0a", "0x0200"), "%" prefix is for binary
+// and "#" for decimal numbers. Operands are expressions of numbers, labels and constants joined with + and -.
+// Instructions are matched against chip8.Opcodes syntax, so the assembler always follows the instruction set.
+package asm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/brus-fabrika/chip8/chip8"
+)
+
+const (
+	MAX_INCLUDE_DEPTH  = 16
+	MAX_CONSTANT_DEPTH = 16 // constants referencing other constants
+)
+
+// Error is an assembling error with the source position
+type Error struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Msg)
+}
+
+// Assembler assembles sources, ReadFile is used for the main file and includes (os.ReadFile if nil)
+type Assembler struct {
+	ReadFile func(name string) ([]byte, error)
+}
+
+// Position is the source line of the assembled statement
+type Position struct {
+	File string
+	Line int
+}
+
+// Program is the assembled ROM with debug information
+type Program struct {
+	ROM       []uint8
+	Positions map[uint16]Position // statement address to its source line
+	Labels    map[string]uint16
+}
+
+// Address returns the address of the first statement at the source line or after it in the same file,
+// the actual line is returned too. False if there is no code at or after the line.
+func (prog *Program) Address(file string, line int) (uint16, int, bool) {
+	found := false
+	var adr uint16
+	actual := 0
+
+	for a, pos := range prog.Positions {
+		if pos.File != file || pos.Line < line {
+			continue
+		}
+		if !found || pos.Line < actual || (pos.Line == actual && a < adr) {
+			adr, actual, found = a, pos.Line, true
+		}
+	}
+
+	return adr, actual, found
+}
+
+// statement is a single source line: instruction, data directive or constant
+type statement struct {
+	file string
+	line int
+
+	mnemonic string   // upper case instruction or directive
+	operands []string // trimmed, not evaluated
+}
+
+// program is the parsed source with symbols, built by the first pass
+type program struct {
+	statements []statement
+	labels     map[string]uint16
+	constants  map[string]statement // constant expressions are evaluated on use
+	adr        int
+}
+
+// AssembleFile assembles the file into ROM image, loaded at 0x200
+func (a *Assembler) AssembleFile(fileName string) ([]uint8, error) {
+	prog, err := a.BuildFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	return prog.ROM, nil
+}
+
+// Assemble assembles the source into ROM image, name is used for errors and includes
+func (a *Assembler) Assemble(name string, src []byte) ([]uint8, error) {
+	prog, err := a.Build(name, src)
+	if err != nil {
+		return nil, err
+	}
+
+	return prog.ROM, nil
+}
+
+// BuildFile assembles the file into ROM image with debug information
+func (a *Assembler) BuildFile(fileName string) (*Program, error) {
+	src, err := a.readFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.Build(fileName, src)
+}
+
+// Build assembles the source into ROM image with debug information, name is used for errors, includes and positions
+func (a *Assembler) Build(name string, src []byte) (*Program, error) {
+	p := &program{
+		labels:    map[string]uint16{},
+		constants: map[string]statement{},
+		adr:       int(chip8.MEMORY_USER),
+	}
+
+	if err := a.parse(p, name, src, 0); err != nil {
+		return nil, err
+	}
+
+	prog := &Program{
+		ROM:       make([]uint8, 0, p.adr-int(chip8.MEMORY_USER)),
+		Positions: map[uint16]Position{},
+		Labels:    p.labels,
+	}
+	for _, st := range p.statements {
+		code, err := p.encode(st)
+		if err != nil {
+			return nil, &Error{File: st.file, Line: st.line, Msg: err.Error()}
+		}
+		prog.Positions[uint16(int(chip8.MEMORY_USER)+len(prog.ROM))] = Position{File: st.file, Line: st.line}
+		prog.ROM = append(prog.ROM, code...)
+	}
+
+	return prog, nil
+}
+
+func (a *Assembler) readFile(name string) ([]byte, error) {
+	if a.ReadFile != nil {
+		return a.ReadFile(name)
+	}
+	return os.ReadFile(name)
+}
+
+// parse is the first pass: splits lines into statements, expands includes and collects labels
+func (a *Assembler) parse(p *program, name string, src []byte, depth int) error {
+	for i, text := range strings.Split(string(src), "\n") {
+		lineErr := func(format string, args ...any) error {
+			return &Error{File: name, Line: i + 1, Msg: fmt.Sprintf(format, args...)}
+		}
+
+		text, _, _ = strings.Cut(text, ";")
+		text = strings.TrimSpace(text)
+
+		// labels
+		for {
+			label, rest, found := strings.Cut(text, ":")
+			if !found || !isIdent(strings.TrimSpace(label)) {
+				break
+			}
+			if err := p.define(strings.TrimSpace(label)); err != nil {
+				return lineErr("%v", err)
+			}
+			p.labels[strings.TrimSpace(label)] = uint16(p.adr)
+			text = strings.TrimSpace(rest)
+		}
+
+		if text == "" {
+			continue
+		}
+
+		st := statement{file: name, line: i + 1}
+
+		// constants
+		if left, right, found := strings.Cut(text, "="); found {
+			constName := strings.TrimSpace(left)
+			if !isIdent(constName) {
+				return lineErr("invalid constant name %q", constName)
+			}
+			if err := p.define(constName); err != nil {
+				return lineErr("%v", err)
+			}
+			st.operands = []string{strings.TrimSpace(right)}
+			p.constants[constName] = st
+			continue
+		}
+
+		mnemonic, args := text, ""
+		if sep := strings.IndexAny(text, " \t"); sep >= 0 {
+			mnemonic, args = text[:sep], strings.TrimSpace(text[sep:])
+		}
+		st.mnemonic = strings.ToUpper(mnemonic)
+		if args != "" {
+			for _, arg := range strings.Split(args, ",") {
+				st.operands = append(st.operands, strings.TrimSpace(arg))
+			}
+		}
+
+		switch st.mnemonic {
+		case "INCLUDE":
+			if depth >= MAX_INCLUDE_DEPTH {
+				return lineErr("includes are nested too deep")
+			}
+			if len(st.operands) != 1 {
+				return lineErr("include expects a file name")
+			}
+			fileName := strings.Trim(st.operands[0], `"`)
+			if !filepath.IsAbs(fileName) {
+				fileName = filepath.Join(filepath.Dir(name), fileName)
+			}
+			src, err := a.readFile(fileName)
+			if err != nil {
+				return lineErr("%v", err)
+			}
+			if err := a.parse(p, fileName, src, depth+1); err != nil {
+				return err
+			}
+			continue
+		case "DB":
+			p.adr += len(st.operands)
+		case "DW":
+			p.adr += 2 * len(st.operands)
+		default:
+			size, ok := instructionSize(st.mnemonic)
+			if !ok {
+				return lineErr("unknown instruction %s", mnemonic)
+			}
+			p.adr += size
+		}
+
+		if p.adr > chip8.MEMORY_SIZE_XO {
+			return lineErr("program does not fit into memory")
+		}
+		p.statements = append(p.statements, st)
+	}
+
+	return nil
+}
+
+// define checks that the symbol is not defined yet
+func (p *program) define(name string) error {
+	if _, ok := p.labels[name]; ok {
+		return fmt.Errorf("%s is already defined", name)
+	}
+	if _, ok := p.constants[name]; ok {
+		return fmt.Errorf("%s is already defined", name)
+	}
+	return nil
+}
+
+// instructionSize returns instruction size by mnemonic, F000 NNNN is the only 4 bytes long one
+func instructionSize(mnemonic string) (int, bool) {
+	for i := range chip8.Opcodes {
+		info := &chip8.Opcodes[i]
+		if info.Mnemonic() == mnemonic {
+			if info.Op == chip8.OpMovILong {
+				return 4, true
+			}
+			return 2, true
+		}
+	}
+	return 0, false
+}
+
+// encode is the second pass: statement to bytes
+func (p *program) encode(st statement) ([]uint8, error) {
+	switch st.mnemonic {
+	case "DB":
+		data := make([]uint8, len(st.operands))
+		for i, operand := range st.operands {
+			val, err := p.eval(operand, 0xff, 0)
+			if err != nil {
+				return nil, err
+			}
+			data[i] = uint8(val)
+		}
+		return data, nil
+	case "DW":
+		data := make([]uint8, 0, 2*len(st.operands))
+		for _, operand := range st.operands {
+			val, err := p.eval(operand, 0xffff, 0)
+			if err != nil {
+				return nil, err
+			}
+			data = append(data, uint8(val>>8), uint8(val))
+		}
+		return data, nil
+	}
+
+	var firstErr error
+	for i := range chip8.Opcodes {
+		info := &chip8.Opcodes[i]
+		if info.Mnemonic() != st.mnemonic {
+			continue
+		}
+
+		code, err := p.encodeInstruction(info, st.operands)
+		if err == nil {
+			return code, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return nil, firstErr
+}
+
+// encodeInstruction matches operands with the syntax template of instruction set entry
+func (p *program) encodeInstruction(info *chip8.OpcodeInfo, operands []string) ([]uint8, error) {
+	var templates []string
+	if _, args, found := strings.Cut(info.Syntax, " "); found {
+		templates = strings.Split(args, ", ")
+	}
+	if len(templates) != len(operands) {
+		return nil, fmt.Errorf("%s expects %d operands", info.Mnemonic(), len(templates))
+	}
+
+	opcode := info.Value
+	var long uint16
+
+	for i, template := range templates {
+		operand := operands[i]
+
+		start := strings.IndexByte(template, '{')
+		if start < 0 {
+			// literal operand, i.e. I or T0
+			if !strings.EqualFold(template, operand) {
+				return nil, fmt.Errorf("invalid operand %q for %s, expected %s", operand, info.Mnemonic(), template)
+			}
+			continue
+		}
+		end := strings.IndexByte(template, '}')
+		prefix, field, suffix := template[:start], template[start+1:end], template[end+1:]
+
+		if len(operand) < len(prefix)+len(suffix) || !strings.EqualFold(operand[:len(prefix)], prefix) || !strings.EqualFold(operand[len(operand)-len(suffix):], suffix) {
+			return nil, fmt.Errorf("invalid operand %q for %s, expected %s", operand, info.Mnemonic(), template)
+		}
+		value := operand[len(prefix) : len(operand)-len(suffix)]
+
+		switch field {
+		case "X", "Y":
+			reg, err := strconv.ParseUint(value, 16, 4)
+			if err != nil || len(value) != 1 {
+				return nil, fmt.Errorf("invalid register %q for %s", operand, info.Mnemonic())
+			}
+			if field == "X" {
+				opcode |= uint16(reg) << 8
+			} else {
+				opcode |= uint16(reg) << 4
+			}
+		case "N":
+			val, err := p.eval(value, 0xf, 0)
+			if err != nil {
+				return nil, err
+			}
+			opcode |= val
+		case "NN":
+			val, err := p.eval(value, 0xff, 0)
+			if err != nil {
+				return nil, err
+			}
+			opcode |= val
+		case "NNN":
+			val, err := p.eval(value, 0xfff, 0)
+			if err != nil {
+				return nil, err
+			}
+			opcode |= val
+		case "NNNN":
+			val, err := p.eval(value, 0xffff, 0)
+			if err != nil {
+				return nil, err
+			}
+			long = val
+		}
+	}
+
+	code := []uint8{uint8(opcode >> 8), uint8(opcode)}
+	if info.Op == chip8.OpMovILong {
+		code = append(code, uint8(long>>8), uint8(long))
+	}
+
+	return code, nil
+}
+
+// eval evaluates expression of numbers and symbols joined with + and -, result must be in 0-limit range
+func (p *program) eval(expr string, limit int, depth int) (uint16, error) {
+	if depth > MAX_CONSTANT_DEPTH {
+		return 0, fmt.Errorf("constants are defined recursively")
+	}
+
+	expr = strings.ReplaceAll(expr, " ", "")
+	if expr == "" {
+		return 0, fmt.Errorf("missing value")
+	}
+
+	result := 0
+	sign := 1
+	for expr != "" {
+		end := strings.IndexAny(expr[1:], "+-") + 1
+		if end == 0 {
+			end = len(expr)
+		}
+		term := expr[:end]
+		expr = expr[end:]
+
+		switch term[0] {
+		case '+':
+			sign, term = 1, term[1:]
+		case '-':
+			sign, term = -1, term[1:]
+		}
+
+		val, err := p.term(term, depth)
+		if err != nil {
+			return 0, err
+		}
+		result += sign * val
+	}
+
+	if result < 0 || result > limit {
+		return 0, fmt.Errorf("value %x out of range 0-%x", result, limit)
+	}
+
+	return uint16(result), nil
+}
+
+// term returns symbol or number value, symbols take precedence over hex numbers (i.e. label "beef")
+func (p *program) term(term string, depth int) (int, error) {
+	if adr, ok := p.labels[term]; ok {
+		return int(adr), nil
+	}
+	if st, ok := p.constants[term]; ok {
+		val, err := p.eval(st.operands[0], 0xffff, depth+1)
+		return int(val), err
+	}
+
+	var val uint64
+	var err error
+	switch {
+	case strings.HasPrefix(term, "#"):
+		val, err = strconv.ParseUint(term[1:], 10, 16)
+	case strings.HasPrefix(term, "%"):
+		val, err = strconv.ParseUint(term[1:], 2, 16)
+	default:
+		val, err = strconv.ParseUint(strings.TrimPrefix(term, "0x"), 16, 16)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", term)
+	}
+
+	return int(val), nil
+}
+
+func isIdent(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for i, r := range s {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
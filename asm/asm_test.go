@@ -0,0 +1,197 @@
+package asm_test
+
+import (
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brus-fabrika/chip8/asm"
+	"github.com/brus-fabrika/chip8/chip8"
+	"github.com/brus-fabrika/chip8/disasm"
+)
+
+func assemble(src string) ([]uint8, error) {
+	assembler := asm.Assembler{}
+	return assembler.Assemble("test.asm", []byte(src))
+}
+
+func TestAssemble(t *testing.T) {
+	src := `
+; draw the sprite forever
+SPRITE_X = #10
+start:  MOV I, sprite
+	MOV V1, SPRITE_X
+	mov v2, 0x1f
+loop:	DRAW 3, V1, V2
+	JMP loop+2 ; into the middle of nowhere
+	MOVL I, sprite-1
+	PLANE 3
+sprite:
+	db %11111111, 81, #255
+	dw start, 0xabcd
+`
+	rom, err := assemble(src)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []uint8{
+			0xa2, 0x10,
+			0x61, 0x0a,
+			0x62, 0x1f,
+			0xd1, 0x23,
+			0x12, 0x08,
+			0xf0, 0x00, 0x02, 0x0f,
+			0xf3, 0x01,
+			0xff, 0x81, 0xff,
+			0x02, 0x00, 0xab, 0xcd,
+		}, rom)
+	}
+}
+
+func TestAssembleInclude(t *testing.T) {
+	files := map[string]string{
+		"main.asm":        "include \"lib/sprites.asm\"\nMOV I, digit\n",
+		"lib/sprites.asm": "digit: db f0, 90\nCLS\n",
+	}
+	assembler := asm.Assembler{ReadFile: func(name string) ([]byte, error) {
+		src, ok := files[name]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		return []byte(src), nil
+	}}
+
+	rom, err := assembler.AssembleFile("main.asm")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []uint8{0xf0, 0x90, 0x00, 0xe0, 0xa2, 0x00}, rom)
+	}
+
+	// absolute path is used as it is
+	absFile := filepath.Join(t.TempDir(), "abs.asm")
+	files[absFile] = "digit: CLS\n"
+	files["lib/sprites.asm"] = "include \"" + absFile + "\"\n"
+	rom, err = assembler.AssembleFile("main.asm")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []uint8{0x00, 0xe0, 0xa2, 0x00}, rom)
+	}
+
+	files["lib/sprites.asm"] = "include \"../main.asm\""
+	_, err = assembler.AssembleFile("main.asm")
+	assert.ErrorContains(t, err, "nested too deep")
+}
+
+func TestBuild(t *testing.T) {
+	src := `start:	CLS
+
+	; comment
+loop:	ADD V1, 01
+	JMP loop
+`
+	assembler := asm.Assembler{}
+	prog, err := assembler.Build("main.asm", []byte(src))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, map[uint16]asm.Position{
+		0x0200: {File: "main.asm", Line: 1},
+		0x0202: {File: "main.asm", Line: 4},
+		0x0204: {File: "main.asm", Line: 5},
+	}, prog.Positions)
+	assert.Equal(t, uint16(0x0202), prog.Labels["loop"])
+
+	testCases := []struct {
+		line   int
+		adr    uint16
+		actual int
+		found  bool
+	}{
+		{1, 0x0200, 1, true},
+		{2, 0x0202, 4, true},
+		{5, 0x0204, 5, true},
+		{6, 0, 0, false},
+	}
+	for _, tc := range testCases {
+		adr, actual, found := prog.Address("main.asm", tc.line)
+		assert.Equal(t, tc.found, found, tc.line)
+		assert.Equal(t, tc.adr, adr, tc.line)
+		assert.Equal(t, tc.actual, actual, tc.line)
+	}
+
+	_, _, found := prog.Address("other.asm", 1)
+	assert.False(t, found)
+}
+
+func TestAssembleErrors(t *testing.T) {
+	testTable := []struct {
+		Name string
+		Src  string
+		Line int
+		Msg  string
+	}{
+		{Name: "UnknownInstruction", Src: "CLS\nFOO V1", Line: 2, Msg: "unknown instruction FOO"},
+		{Name: "OutOfRange", Src: "MOV V1, 100", Line: 1, Msg: "out of range"},
+		{Name: "BadRegister", Src: "SHR V1, VG", Line: 1, Msg: "invalid register"},
+		{Name: "OperandsCount", Src: "CLS V1", Line: 1, Msg: "expects 0 operands"},
+		{Name: "UnknownSymbol", Src: "JMP nowhere", Line: 1, Msg: "invalid value"},
+		{Name: "DuplicateLabel", Src: "a:\nb:\na:", Line: 3, Msg: "already defined"},
+		{Name: "RecursiveConstant", Src: "A = B\nB = A\nMOV V1, A", Line: 3, Msg: "recursively"},
+	}
+
+	for _, tc := range testTable {
+		t.Run(tc.Name, func(t *testing.T) {
+			_, err := assemble(tc.Src)
+
+			var asmErr *asm.Error
+			if assert.True(t, errors.As(err, &asmErr)) {
+				assert.Equal(t, tc.Line, asmErr.Line)
+				assert.Contains(t, asmErr.Msg, tc.Msg)
+			}
+		})
+	}
+}
+
+// every opcode bit must be either fixed by the mask or come from an operand,
+// otherwise disassembled instruction could not be assembled back to the same bytes
+func TestOpcodesSyntaxCoversAllBits(t *testing.T) {
+	fields := map[string]uint16{"{X}": 0x0f00, "{Y}": 0x00f0, "{N}": 0x000f, "{NN}": 0x00ff, "{NNN}": 0x0fff}
+
+	for _, info := range chip8.Opcodes {
+		bits := info.Mask
+		for field, mask := range fields {
+			if strings.Contains(info.Syntax, field) {
+				bits |= mask
+			}
+		}
+		assert.Equal(t, uint16(0xffff), bits, info.Code)
+	}
+}
+
+func roundTrip(t *testing.T, rom []uint8, ver chip8.ChipVersion) {
+	var listing strings.Builder
+	assert.NoError(t, disasm.Trace(rom, ver).Write(&listing))
+
+	res, err := assemble(listing.String())
+	if assert.NoError(t, err, listing.String()) {
+		assert.Equal(t, rom, res, listing.String())
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, info := range chip8.Opcodes {
+		for _, operands := range []uint16{0x0000, 0x0123, 0x0fed} {
+			cmd := info.Value | operands&^info.Mask
+			roundTrip(t, []uint8{uint8(cmd >> 8), uint8(cmd), 0xf0, 0x00, 0x12, 0x34}, chip8.XO_Chip)
+		}
+	}
+
+	rnd := rand.New(rand.NewSource(8))
+	for _, ver := range []chip8.ChipVersion{chip8.Chip_8, chip8.Super_Chip_Modern, chip8.XO_Chip} {
+		rom := make([]uint8, 0x400)
+		rnd.Read(rom)
+		roundTrip(t, rom, ver)
+	}
+}
@@ -0,0 +1,135 @@
+// Package audio generates the sound timer tone. Samples are mono float32 in -1..1 range,
+// the generator writes them into a Sink: SDL audio device in the frontend, memory in tests.
+package audio
+
+import (
+	"math"
+
+	"github.com/brus-fabrika/chip8/chip8"
+	"github.com/brus-fabrika/chip8/internal/enum"
+)
+
+const (
+	SAMPLE_RATE       = 44100
+	DEFAULT_FREQUENCY = 440
+	DEFAULT_VOLUME    = 0.25
+	DEFAULT_RAMP      = SAMPLE_RATE / 200 // 5 ms fade in and out, so the tone doesn't click
+)
+
+type Waveform int
+
+const (
+	Square Waveform = iota
+	Sine
+	Triangle
+)
+
+var waveformNames = enum.Names[Waveform]{Type: "Waveform", Desc: "waveform", Names: []string{"square", "sine", "triangle"}}
+
+func (w Waveform) String() string {
+	return waveformNames.String(w)
+}
+
+// ParseWaveform parses waveform name: square, sine or triangle
+func ParseWaveform(name string) (Waveform, error) {
+	return waveformNames.Parse(name)
+}
+
+// sample returns the wave value at phase 0..1
+func (w Waveform) sample(phase float64) float64 {
+	switch w {
+	case Sine:
+		return math.Sin(2 * math.Pi * phase)
+	case Triangle:
+		return 4*math.Abs(phase-0.5) - 1
+	}
+	if phase < 0.5 {
+		return 1
+	}
+	return -1
+}
+
+// Sink receives generated samples
+type Sink interface {
+	Write(samples []float32) error
+}
+
+// MemorySink keeps all the samples written, i.e. to check them in tests
+type MemorySink struct {
+	Samples []float32
+}
+
+func (s *MemorySink) Write(samples []float32) error {
+	s.Samples = append(s.Samples, samples...)
+	return nil
+}
+
+// Generator produces the tone while it is on. Switching on, off and muting
+// fade the volume in Ramp samples instead of cutting the wave.
+type Generator struct {
+	Waveform   Waveform
+	Frequency  float64 // Hz
+	Volume     float64 // 0..1
+	Muted      bool
+	SampleRate int
+	Ramp       int // samples to fade in and out, 0 cuts immediately
+
+	phase float64 // position in the wave period, 0..1
+	gain  float64 // current fade level, 0..1
+	buf   []float32
+}
+
+func NewGenerator() *Generator {
+	return &Generator{
+		Waveform:   Square,
+		Frequency:  DEFAULT_FREQUENCY,
+		Volume:     DEFAULT_VOLUME,
+		SampleRate: SAMPLE_RATE,
+		Ramp:       DEFAULT_RAMP,
+	}
+}
+
+// Generate fills samples with the tone, on tells if the tone should sound
+func (g *Generator) Generate(samples []float32, on bool) {
+	target := 0.0
+	if on && !g.Muted {
+		target = 1
+	}
+
+	step := 1.0
+	if g.Ramp > 0 {
+		step = 1 / float64(g.Ramp)
+	}
+
+	for i := range samples {
+		switch {
+		case g.gain < target:
+			g.gain = math.Min(g.gain+step, target)
+		case g.gain > target:
+			g.gain = math.Max(g.gain-step, target)
+		}
+
+		if g.gain == 0 {
+			// silence, next tone starts from the beginning of the period
+			g.phase = 0
+			samples[i] = 0
+			continue
+		}
+
+		samples[i] = float32(g.Waveform.sample(g.phase) * g.gain * g.Volume)
+		g.phase += g.Frequency / float64(g.SampleRate)
+		g.phase -= math.Floor(g.phase)
+	}
+}
+
+// Frame generates one emulated frame (1/60 s) of samples into the sink
+func (g *Generator) Frame(sink Sink, on bool) error {
+	n := g.SampleRate / chip8.FRAME_RATE
+	if cap(g.buf) < n {
+		g.buf = make([]float32, n)
+	}
+	g.buf = g.buf[:n]
+
+	g.Generate(g.buf, on)
+	return sink.Write(g.buf)
+}
@@ -0,0 +1,89 @@
+package audio_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brus-fabrika/chip8/audio"
+)
+
+// quarter of the sample rate gives 4 samples per wave period
+func newGenerator(w audio.Waveform) *audio.Generator {
+	g := audio.NewGenerator()
+	g.Waveform = w
+	g.SampleRate = 400
+	g.Frequency = 100
+	g.Volume = 1
+	g.Ramp = 0
+	return g
+}
+
+func TestWaveforms(t *testing.T) {
+	tests := []struct {
+		name     string
+		waveform audio.Waveform
+		want     []float32
+	}{
+		{"Square", audio.Square, []float32{1, 1, -1, -1, 1, 1, -1, -1}},
+		{"Sine", audio.Sine, []float32{0, 1, 0, -1, 0, 1, 0, -1}},
+		{"Triangle", audio.Triangle, []float32{1, 0, -1, 0, 1, 0, -1, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newGenerator(tt.waveform)
+			samples := make([]float32, 8)
+			g.Generate(samples, true)
+			assert.InDeltaSlice(t, tt.want, samples, 1e-6)
+		})
+	}
+}
+
+func TestGenerateOff(t *testing.T) {
+	g := newGenerator(audio.Square)
+	samples := []float32{1, 1, 1, 1}
+	g.Generate(samples, false)
+	assert.Equal(t, []float32{0, 0, 0, 0}, samples)
+
+	g.Generate(samples, true)
+	g.Muted = true
+	g.Generate(samples, true)
+	assert.Equal(t, []float32{0, 0, 0, 0}, samples)
+}
+
+func TestRamp(t *testing.T) {
+	g := newGenerator(audio.Square)
+	g.Frequency = 0 // constant 1, so only the fade is seen
+	g.Ramp = 4
+
+	samples := make([]float32, 6)
+	g.Generate(samples, true)
+	assert.InDeltaSlice(t, []float32{0.25, 0.5, 0.75, 1, 1, 1}, samples, 1e-6)
+
+	g.Generate(samples, false)
+	assert.InDeltaSlice(t, []float32{0.75, 0.5, 0.25, 0, 0, 0}, samples, 1e-6)
+
+	// mute fades out the same way
+	g.Generate(samples, true)
+	g.Muted = true
+	g.Generate(samples[:2], true)
+	assert.InDeltaSlice(t, []float32{0.75, 0.5}, samples[:2], 1e-6)
+}
+
+func TestFrame(t *testing.T) {
+	g := audio.NewGenerator()
+	sink := &audio.MemorySink{}
+
+	assert.NoError(t, g.Frame(sink, true))
+	assert.NoError(t, g.Frame(sink, false))
+	assert.Len(t, sink.Samples, 2*audio.SAMPLE_RATE/60)
+
+	// tone is in the first frame only, it has faded out by the end of the second one
+	peak := float32(0)
+	for _, s := range sink.Samples[:audio.SAMPLE_RATE/60] {
+		peak = max(peak, s)
+	}
+	assert.InDelta(t, audio.DEFAULT_VOLUME, peak, 1e-6)
+	assert.Zero(t, sink.Samples[len(sink.Samples)-1])
+}
@@ -3,20 +3,27 @@ package chip8
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"math/rand"
 	"os"
+	"strings"
 )
 
 const (
-	DISPLAY_WIDTH          = 64
-	DISPLAY_HEIGHT         = 32
-	MEMORY_SIZE     uint16 = 0x1000
-	MEMORY_FONT     uint16 = 0x01B0
-	MEMORY_USER     uint16 = 0x0200
-	MEMORY_STACK    uint16 = MEMORY_SIZE - 0x0200 + 0x00a0
-	MEMORY_INT_AREA uint16 = MEMORY_STACK + 0x0030
-	MEMORY_REG_AREA uint16 = MEMORY_INT_AREA + 0x0020
-	MEMORY_DISPLAY  uint16 = MEMORY_REG_AREA + 0x0010
+	DISPLAY_WIDTH               = 128 // display buffer is always in hires, lores pixels are drawn as 2x2 blocks
+	DISPLAY_HEIGHT              = 64
+	DISPLAY_LORES_WIDTH         = 64
+	DISPLAY_LORES_HEIGHT        = 32
+	MEMORY_SIZE          uint16 = 0x1000
+	MEMORY_SIZE_XO              = 0x10000 // XO-CHIP 64K address space
+	MEMORY_STACK_XO      uint16 = 0x0040  // XO-CHIP has no free space above user area, so stack is in interpreter area
+	MEMORY_BIG_FONT      uint16 = 0x0110
+	MEMORY_FONT          uint16 = 0x01B0
+	MEMORY_USER          uint16 = 0x0200
+	MEMORY_STACK         uint16 = MEMORY_SIZE - 0x0200 + 0x00a0
+	MEMORY_INT_AREA      uint16 = MEMORY_STACK + 0x0030
+	MEMORY_REG_AREA      uint16 = MEMORY_INT_AREA + 0x0020
+	MEMORY_DISPLAY       uint16 = MEMORY_REG_AREA + 0x0010
 )
 
 var font_data = []uint8{
@@ -73,18 +80,84 @@ const (
 	Super_Chip_Legacy
 )
 
+func (ver ChipVersion) String() string {
+	switch ver {
+	case Chip_8:
+		return "CHIP-8"
+	case Super_Chip_Modern:
+		return "SCHIP-modern"
+	case XO_Chip:
+		return "XO-CHIP"
+	case Super_Chip_Legacy:
+		return "SCHIP-legacy"
+	}
+
+	return fmt.Sprintf("ChipVersion(%d)", int(ver))
+}
+
+// ParseChipVersion parses platform name as returned by String, or its short form (chip8, schip, schip-legacy, xo)
+func ParseChipVersion(name string) (ChipVersion, error) {
+	switch strings.ToLower(name) {
+	case "chip-8", "chip8":
+		return Chip_8, nil
+	case "schip-modern", "schip":
+		return Super_Chip_Modern, nil
+	case "schip-legacy":
+		return Super_Chip_Legacy, nil
+	case "xo-chip", "xo":
+		return XO_Chip, nil
+	}
+
+	return Chip_8, fmt.Errorf("unknown platform %q", name)
+}
+
 type Chip8 struct {
 	Ver           ChipVersion
-	Memory        [MEMORY_SIZE]uint8
-	DisplayBuffer [DISPLAY_WIDTH * DISPLAY_HEIGHT]bool
-	Keyboard      [0x10]bool
-	Reg           RegisterSet
+	Quirks        Quirks
+	Memory        [MEMORY_SIZE_XO]uint8                 // only first MemorySize() bytes are addressable
+	DisplayBuffer [DISPLAY_WIDTH * DISPLAY_HEIGHT]uint8 // bit per plane, only plane 1 on CHIP-8 and SCHIP
+	Hires         bool                                  // SCHIP 128x64 mode
+	Planes        uint8                                 // XO-CHIP drawing planes mask, plane 1 by default
+
+	AudioPattern [16]uint8 // XO-CHIP 1-bit audio pattern, 128 samples
+	AudioPitch   uint8     // XO-CHIP audio pattern playback pitch, 64 is 4000 samples per second
+	Keyboard     [0x10]bool
+	keyLatch     int // key pressed while waiting in FX0A, -1 if none yet
+	Reg          RegisterSet
 
 	RomSize uint16 // just for control and debug
 
+	MaxStackDepth int // subroutines nesting limit, set by Init from the platform preset
+
+	rng uint32 // random generator state, kept in snapshots so the replay is the same
+
+	RPL   [16]uint8  // SCHIP user flags (HP48 RPL registers), not cleared by Init
+	Flags FlagsStore // optional persistent storage for RPL flags
+
+	OnError ErrorPolicy          // what to do when instruction execution fails
+	OnTrap  func(err *ExecError) // optional handler called with PolicyTrap
+
+	Watchpoints []Watchpoint        // memory ranges which pause the machine on access
+	OnWatch     func(hit *WatchHit) // optional handler called when watchpoint pauses the machine
+	watchHit    *WatchHit           // watchpoint hit by the current instruction
+
+	CyclesPerFrame int       // instructions per 60 Hz frame in Run, DEFAULT_CYCLES_PER_FRAME if not set
+	Cycles         uint64    // instructions executed since Init
+	frameSteps     int       // instructions executed in the current frame
+	Tracer         Tracer    // optional receiver of every executed instruction
+	Profiler       *Profiler // optional executed instructions counter
+	Coverage       *Coverage // optional memory accesses record
+	OnFrame        func()    // optional handler called by Run after every frame
+
 	State struct {
 		Running bool
 		Paused  bool
+		Err     error // last execution error
+
+		WaitingForVBlank bool // DXYN with display wait quirk, execution yields until the next frame
+		WaitingForKey    bool // FX0A waits for key press and release
+
+		Watch *WatchHit // last watchpoint hit
 	}
 }
 
@@ -136,46 +209,177 @@ func (chip *Chip8) setRegister(r Register, val uint16) {
 }
 
 func (chip *Chip8) ClearScreen() {
+	// only selected planes are cleared
 	for i := 0; i < DISPLAY_WIDTH*DISPLAY_HEIGHT; i++ {
-		chip.DisplayBuffer[i] = false
+		chip.DisplayBuffer[i] &^= chip.Planes
 	}
 	chip.Reg.PC += 2
 }
 
-func (chip *Chip8) DisplayAt(xr, yr Register, h int) {
-	x := int(chip.getRegister(xr)) & (DISPLAY_WIDTH - 1)
-	y := int(chip.getRegister(yr)) & (DISPLAY_HEIGHT - 1)
+func (chip *Chip8) DisplayAt(xr, yr Register, h int) error {
+	w, ht := chip.DisplaySize()
+
+	x := int(chip.getRegister(xr)) & (w - 1)
+	y := int(chip.getRegister(yr)) & (ht - 1)
+
+	// DXY0 draws 16x16 sprite, except original CHIP-8
+	width := 8
+	if h == 0 && chip.Ver != Chip_8 {
+		width, h = 16, 16
+	}
 
+	// XO-CHIP takes sprite data for each selected plane one after another
+	spriteSize := h * width / 8
 	dataOffset := int(chip.Reg.I)
-	data := chip.Memory[dataOffset : dataOffset+h]
+	if dataOffset+spriteSize*chip.planesCount() > chip.MemorySize() {
+		return ErrMemoryOutOfBounds
+	}
+	chip.watch(dataOffset, spriteSize*chip.planesCount(), WatchRead)
 
-	chip.Reg.V[0x0F] = 0x00
+	collisions := 0
 
-	for yOffset, v := range data {
-		if y+yOffset >= DISPLAY_HEIGHT {
-			break
+	for plane := uint8(1); plane <= 2; plane <<= 1 {
+		if chip.Planes&plane == 0 {
+			continue
 		}
-		for xOffset := 0; xOffset < 8; xOffset++ {
-			if x+xOffset >= DISPLAY_WIDTH {
-				break
+
+		planeCollisions := 0
+
+		for yOffset := 0; yOffset < h; yOffset++ {
+			py := y + yOffset
+			if py >= ht {
+				if chip.Quirks.ClipSprites {
+					if chip.Hires && chip.Quirks.CollisionRows {
+						// SCHIP 1.1 counts rows clipped at the bottom as collided
+						planeCollisions += h - yOffset
+					}
+					break
+				}
+				py &= ht - 1
+			}
+
+			var rowData uint16
+			if width == 16 {
+				rowData = uint16(chip.Memory[dataOffset+yOffset*2])<<8 + uint16(chip.Memory[dataOffset+yOffset*2+1])
+			} else {
+				rowData = uint16(chip.Memory[dataOffset+yOffset])
 			}
 
-			spriteBit := v & (1 << (7 - xOffset))
+			rowCollision := false
+			for xOffset := 0; xOffset < width; xOffset++ {
+				px := x + xOffset
+				if px >= w {
+					if chip.Quirks.ClipSprites {
+						break
+					}
+					px &= w - 1
+				}
+
+				spriteBit := rowData & (1 << (width - 1 - xOffset))
 
-			if spriteBit != 0 {
-				index := x + xOffset + (y+yOffset)*DISPLAY_WIDTH
-				cv := chip.DisplayBuffer[index]
-				if cv {
-					chip.DisplayBuffer[index] = false
-					chip.Reg.V[0x0F] = 0x01
-				} else {
-					chip.DisplayBuffer[index] = true
+				if spriteBit != 0 && chip.togglePixel(px, py, plane) {
+					rowCollision = true
 				}
 			}
+
+			if rowCollision {
+				planeCollisions++
+			}
 		}
+
+		collisions = max(collisions, planeCollisions)
+		dataOffset += spriteSize
+	}
+
+	if chip.Hires && chip.Quirks.CollisionRows {
+		chip.Reg.V[0x0F] = uint8(collisions)
+	} else if collisions > 0 {
+		chip.Reg.V[0x0F] = 0x01
+	} else {
+		chip.Reg.V[0x0F] = 0x00
+	}
+
+	if chip.Quirks.DisplayWait {
+		// COSMAC VIP draws in sync with display refresh, so only one sprite per frame
+		chip.State.WaitingForVBlank = true
 	}
 
 	chip.Reg.PC += 2
+	return nil
+}
+
+// togglePixel flips the pixel plane at the current resolution coordinates, returns true if it was set before
+func (chip *Chip8) togglePixel(x, y int, plane uint8) bool {
+	scale := chip.displayScale()
+	collision := false
+
+	for dy := 0; dy < scale; dy++ {
+		for dx := 0; dx < scale; dx++ {
+			index := x*scale + dx + (y*scale+dy)*DISPLAY_WIDTH
+			if chip.DisplayBuffer[index]&plane != 0 {
+				collision = true
+			}
+			chip.DisplayBuffer[index] ^= plane
+		}
+	}
+
+	return collision
+}
+
+// planesCount returns the number of selected drawing planes
+func (chip *Chip8) planesCount() int {
+	return int(chip.Planes&1 + chip.Planes>>1&1)
+}
+
+// DisplaySize returns the current screen resolution, 64x32 in lores and 128x64 in hires
+func (chip *Chip8) DisplaySize() (int, int) {
+	if chip.Hires {
+		return DISPLAY_WIDTH, DISPLAY_HEIGHT
+	}
+	return DISPLAY_LORES_WIDTH, DISPLAY_LORES_HEIGHT
+}
+
+// displayScale returns the number of DisplayBuffer pixels per screen pixel
+func (chip *Chip8) displayScale() int {
+	if chip.Hires {
+		return 1
+	}
+	return 2
+}
+
+// Pixel returns true if pixel is set on any plane at the current resolution coordinates
+func (chip *Chip8) Pixel(x, y int) bool {
+	return chip.PixelPlanes(x, y) != 0
+}
+
+// PixelPlanes returns pixel planes bits (color index 0-3) at the current resolution coordinates
+func (chip *Chip8) PixelPlanes(x, y int) uint8 {
+	scale := chip.displayScale()
+	return chip.DisplayBuffer[x*scale+y*scale*DISPLAY_WIDTH]
+}
+
+// MemorySize returns the addressable memory size of the platform, 64K on XO-CHIP and 4K otherwise
+func (chip *Chip8) MemorySize() int {
+	if chip.Ver == XO_Chip {
+		return MEMORY_SIZE_XO
+	}
+	return int(MEMORY_SIZE)
+}
+
+// StackBase returns the start of the call stack area, STACK_SIZE bytes long
+func (chip *Chip8) StackBase() uint16 {
+	if chip.Ver == XO_Chip {
+		return MEMORY_STACK_XO
+	}
+	return MEMORY_STACK
+}
+
+// instructionSize returns the size of instruction at address, XO-CHIP F000 NNNN is 4 bytes long
+func (chip *Chip8) instructionSize(adr uint16) uint16 {
+	if chip.Ver == XO_Chip && int(adr)+1 < chip.MemorySize() && chip.Memory[adr] == 0xf0 && chip.Memory[adr+1] == 0x00 {
+		return 4
+	}
+	return 2
 }
 
 func (chip *Chip8) MovRegVal(r Register, val uint16) {
@@ -184,11 +388,28 @@ func (chip *Chip8) MovRegVal(r Register, val uint16) {
 }
 
 func (chip *Chip8) MovRegRnd(r Register, mask uint8) {
-	rnd := uint8(rand.Intn(0x100)) & mask
+	rnd := chip.random() & mask
 	chip.setRegister(r, uint16(rnd))
 	chip.Reg.PC += 2
 }
 
+// Seed sets random generator state, i.e. for reproducible runs
+func (chip *Chip8) Seed(seed uint32) {
+	if seed == 0 {
+		// xorshift is stuck at zero
+		seed = 1
+	}
+	chip.rng = seed
+}
+
+// random returns next xorshift32 random byte
+func (chip *Chip8) random() uint8 {
+	chip.rng ^= chip.rng << 13
+	chip.rng ^= chip.rng >> 17
+	chip.rng ^= chip.rng << 5
+	return uint8(chip.rng >> 24)
+}
+
 func (chip *Chip8) MovRegReg(rdst, rsrc Register) {
 	chip.setRegister(rdst, chip.getRegister(rsrc))
 	chip.Reg.PC += 2
@@ -201,6 +422,22 @@ func (chip *Chip8) AddRegVal(r Register, val uint8) {
 	chip.Reg.PC += 2
 }
 
+func (chip *Chip8) AddIReg(r Register) {
+	result := chip.Reg.I + chip.getRegister(r)
+
+	if chip.Quirks.AddIOverflow {
+		// Amiga interpreter sets carry flag when I leaves 12 bit address space
+		if result > 0x0fff {
+			chip.setRegister(RegVF, 0x01)
+		} else {
+			chip.setRegister(RegVF, 0x00)
+		}
+	}
+
+	chip.Reg.I = result
+	chip.Reg.PC += 2
+}
+
 func (chip *Chip8) AddRegReg(r1, r2 Register) {
 	result := chip.getRegister(r1) + chip.getRegister(r2)
 
@@ -253,26 +490,32 @@ func (chip *Chip8) SubNegRegReg(r1, r2 Register) {
 func (chip *Chip8) Or(r1, r2 Register) {
 	result := chip.getRegister(r1) | chip.getRegister(r2)
 	chip.setRegister(r1, result)
-	chip.setRegister(RegVF, 0x00)
+	if chip.Quirks.VFReset {
+		chip.setRegister(RegVF, 0x00)
+	}
 	chip.Reg.PC += 2
 }
 
 func (chip *Chip8) Xor(r1, r2 Register) {
 	result := chip.getRegister(r1) ^ chip.getRegister(r2)
 	chip.setRegister(r1, result)
-	chip.setRegister(RegVF, 0x00)
+	if chip.Quirks.VFReset {
+		chip.setRegister(RegVF, 0x00)
+	}
 	chip.Reg.PC += 2
 }
 
 func (chip *Chip8) And(r1, r2 Register) {
 	result := chip.getRegister(r1) & chip.getRegister(r2)
 	chip.setRegister(r1, result)
-	chip.setRegister(RegVF, 0x00)
+	if chip.Quirks.VFReset {
+		chip.setRegister(RegVF, 0x00)
+	}
 	chip.Reg.PC += 2
 }
 
 func (chip *Chip8) ShiftR(r1, r2 Register) {
-	if chip.Ver == Chip_8 {
+	if chip.Quirks.ShiftVY {
 		chip.setRegister(r1, chip.getRegister(r2))
 	}
 
@@ -287,7 +530,7 @@ func (chip *Chip8) ShiftR(r1, r2 Register) {
 }
 
 func (chip *Chip8) ShiftL(r1, r2 Register) {
-	if chip.Ver == Chip_8 {
+	if chip.Quirks.ShiftVY {
 		chip.setRegister(r1, chip.getRegister(r2))
 	}
 
@@ -306,29 +549,48 @@ func (chip *Chip8) Jump(adr uint16) {
 }
 
 func (chip *Chip8) JumpV(adr uint16) {
+	if chip.Quirks.JumpVX {
+		// BXNN: the highest digit of address is used as register index
+		chip.Reg.PC = adr + uint16(chip.Reg.V[adr>>8&0x0f])
+		return
+	}
 	chip.Reg.PC = adr + uint16(chip.Reg.V[0])
 }
 
-func (chip *Chip8) Call(adr uint16) {
+func (chip *Chip8) Call(adr uint16) error {
+	// two bytes of return address should fit into stack area within the platform nesting limit
+	if chip.Reg.SP < chip.StackBase()+1 || chip.Reg.SP > chip.stackTop() || chip.StackDepth() >= chip.StackLimit() {
+		return ErrStackOverflow
+	}
+
+	chip.watch(int(chip.Reg.SP)-1, 2, WatchWrite)
 	chip.Memory[chip.Reg.SP] = uint8(chip.Reg.PC)
 	chip.Memory[chip.Reg.SP-1] = uint8(chip.Reg.PC >> 8)
 	chip.Reg.SP -= 2
 
 	chip.Reg.PC = adr
+	return nil
 }
 
-func (chip *Chip8) Ret() {
+func (chip *Chip8) Ret() error {
+	// return address should be pushed onto the stack before
+	if chip.Reg.SP+2 > chip.stackTop() || chip.Reg.SP < chip.StackBase()-1 {
+		return ErrStackUnderflow
+	}
+
+	chip.watch(int(chip.Reg.SP)+1, 2, WatchRead)
 	chip.Reg.PC = uint16(chip.Memory[chip.Reg.SP+1])<<8 + uint16(chip.Memory[chip.Reg.SP+2])
 	chip.Reg.SP += 2
 
 	chip.Reg.PC += 2
+	return nil
 }
 
 func (chip *Chip8) SkipEqualVal(reg Register, val uint8) {
 	if chip.getRegister(reg) != uint16(val) {
 		chip.Reg.PC += 2
 	} else {
-		chip.Reg.PC += 4
+		chip.Reg.PC += 2 + chip.instructionSize(chip.Reg.PC+2)
 	}
 }
 
@@ -336,7 +598,7 @@ func (chip *Chip8) SkipNotEqualVal(reg Register, val uint8) {
 	if chip.getRegister(reg) == uint16(val) {
 		chip.Reg.PC += 2
 	} else {
-		chip.Reg.PC += 4
+		chip.Reg.PC += 2 + chip.instructionSize(chip.Reg.PC+2)
 	}
 }
 
@@ -344,7 +606,7 @@ func (chip *Chip8) SkipEqualReg(r1 Register, r2 Register) {
 	if chip.getRegister(r1) != chip.getRegister(r2) {
 		chip.Reg.PC += 2
 	} else {
-		chip.Reg.PC += 4
+		chip.Reg.PC += 2 + chip.instructionSize(chip.Reg.PC+2)
 	}
 }
 
@@ -352,50 +614,74 @@ func (chip *Chip8) SkipNotEqualReg(r1 Register, r2 Register) {
 	if chip.getRegister(r1) == chip.getRegister(r2) {
 		chip.Reg.PC += 2
 	} else {
-		chip.Reg.PC += 4
+		chip.Reg.PC += 2 + chip.instructionSize(chip.Reg.PC+2)
 	}
 }
 
 func (chip *Chip8) SkipKeyPressedAtReg(r Register) {
-	if chip.Keyboard[chip.getRegister(r)] {
-		chip.Reg.PC += 4
+	// only the lowest hex digit is used as key index
+	if chip.Keyboard[chip.getRegister(r)&0x0f] {
+		chip.Reg.PC += 2 + chip.instructionSize(chip.Reg.PC+2)
 	} else {
 		chip.Reg.PC += 2
 	}
 }
 
 func (chip *Chip8) SkipKeyNotPressedAtReg(r Register) {
-	if !chip.Keyboard[chip.getRegister(r)] {
-		chip.Reg.PC += 4
+	// only the lowest hex digit is used as key index
+	if !chip.Keyboard[chip.getRegister(r)&0x0f] {
+		chip.Reg.PC += 2 + chip.instructionSize(chip.Reg.PC+2)
 	} else {
 		chip.Reg.PC += 2
 	}
 }
 
-func (chip *Chip8) BcdReg(r Register) {
+func (chip *Chip8) BcdReg(r Register) error {
+	if int(chip.Reg.I)+2 >= chip.MemorySize() {
+		return ErrMemoryOutOfBounds
+	}
 
 	origVal := uint8(chip.getRegister(r))
 
+	chip.watch(int(chip.Reg.I), 3, WatchWrite)
+
 	chip.Memory[chip.Reg.I+0] = origVal / 100
 	chip.Memory[chip.Reg.I+1] = (origVal % 100) / 10
 	chip.Memory[chip.Reg.I+2] = (origVal % 10)
 	chip.Reg.PC += 2
+	return nil
 }
 
-func (chip *Chip8) CopyRegToMem(r Register) {
+func (chip *Chip8) CopyRegToMem(r Register) error {
+	if int(chip.Reg.I)+int(r) >= chip.MemorySize() {
+		return ErrMemoryOutOfBounds
+	}
+
+	chip.watch(int(chip.Reg.I), int(r)+1, WatchWrite)
 	for x := 0; x <= int(r); x++ {
-		chip.Memory[chip.Reg.I] = chip.Reg.V[Register(x)]
-		chip.Reg.I++
+		chip.Memory[int(chip.Reg.I)+x] = chip.Reg.V[Register(x)]
+	}
+	if chip.Quirks.LoadStoreIncI {
+		chip.Reg.I += uint16(r) + 1
 	}
 	chip.Reg.PC += 2
+	return nil
 }
 
-func (chip *Chip8) CopyMemToReg(r Register) {
+func (chip *Chip8) CopyMemToReg(r Register) error {
+	if int(chip.Reg.I)+int(r) >= chip.MemorySize() {
+		return ErrMemoryOutOfBounds
+	}
+
+	chip.watch(int(chip.Reg.I), int(r)+1, WatchRead)
 	for x := 0; x <= int(r); x++ {
-		chip.Reg.V[Register(x)] = chip.Memory[chip.Reg.I]
-		chip.Reg.I++
+		chip.Reg.V[Register(x)] = chip.Memory[int(chip.Reg.I)+x]
+	}
+	if chip.Quirks.LoadStoreIncI {
+		chip.Reg.I += uint16(r) + 1
 	}
 	chip.Reg.PC += 2
+	return nil
 }
 
 func (chip *Chip8) SetCharReg(r Register) {
@@ -405,18 +691,28 @@ func (chip *Chip8) SetCharReg(r Register) {
 	chip.Reg.PC += 2
 }
 
+// GetKeyReg waits for a key press and release as the original platform does,
+// PC stays at the instruction until the pressed key is released
 func (chip *Chip8) GetKeyReg(r Register) {
-	anyKeyPressed := false
+	if !chip.State.WaitingForKey {
+		chip.State.WaitingForKey = true
+		chip.keyLatch = -1
+	}
 
-	for key, pressed := range chip.Keyboard {
-		if pressed {
-			chip.setRegister(r, uint16(key))
-			anyKeyPressed = true
-			break
+	if chip.keyLatch < 0 {
+		for key, pressed := range chip.Keyboard {
+			if pressed {
+				chip.keyLatch = key
+				break
+			}
 		}
+		return
 	}
 
-	if anyKeyPressed {
+	if !chip.Keyboard[chip.keyLatch] {
+		chip.setRegister(r, uint16(chip.keyLatch))
+		chip.State.WaitingForKey = false
+		chip.keyLatch = -1
 		chip.Reg.PC += 2
 	}
 }
@@ -428,13 +724,29 @@ func (chip *Chip8) ClearKeyboard() {
 	}
 }
 
+// SetKeyboard replaces the keypad state, i.e. with the live one after Restore,
+// FX0A waiting for the release of a key that is not held anymore waits for a new press
+func (chip *Chip8) SetKeyboard(keys [0x10]bool) {
+	chip.Keyboard = keys
+	if chip.keyLatch >= 0 && !keys[chip.keyLatch] {
+		chip.keyLatch = -1
+	}
+}
+
 func (chip *Chip8) Init(ver ChipVersion) {
 	chip.Ver = ver
+	chip.Quirks = DefaultQuirks(ver)
+	chip.MaxStackDepth = DefaultStackDepth(ver)
+	chip.Hires = false
+	chip.Planes = 0x01
+	chip.AudioPattern = [16]uint8{}
+	chip.AudioPitch = 64
 
-	chip.ClearScreen()
+	// all planes, not only the selected ones as CLS does
+	chip.DisplayBuffer = [DISPLAY_WIDTH * DISPLAY_HEIGHT]uint8{}
 
 	// clear memory
-	for i := 0; i < int(MEMORY_SIZE); i++ {
+	for i := range chip.Memory {
 		chip.Memory[i] = 0
 	}
 
@@ -446,169 +758,105 @@ func (chip *Chip8) Init(ver ChipVersion) {
 	}
 
 	chip.LoadFontFromData(font_data)
+	copy(chip.Memory[MEMORY_BIG_FONT:], big_font_data)
 
-	chip.Reg.PC = MEMORY_USER           // set programm counter at the beginning of user prog area
-	chip.Reg.SP = MEMORY_STACK + 0x002f // set stack pointer at the last byte of stack area
+	chip.Reg.PC = MEMORY_USER     // set programm counter at the beginning of user prog area
+	chip.Reg.SP = chip.stackTop() // set stack pointer at the last byte of stack area
 	chip.Reg.I = 0
 	chip.Reg.T0 = 0
 	chip.Reg.T1 = 0
 
 	chip.State.Running = true
 	chip.State.Paused = false
+	chip.State.WaitingForVBlank = false
+	chip.State.WaitingForKey = false
+	chip.keyLatch = -1
+	chip.watchHit = nil
+	chip.State.Watch = nil
+
+	chip.Seed(rand.Uint32())
+	chip.State.Err = nil
+	chip.Cycles = 0
+	chip.frameSteps = 0
 
 }
 
 func (chip *Chip8) Execute() {
-	var cmd uint16
 	for i := 0; i < 40; /*int(chip.RomSize)*/ i += 2 {
-		cmd = uint16(chip.Memory[int(chip.Reg.PC)])<<8 + uint16(chip.Memory[int(chip.Reg.PC+1)])
-		chip.ProcessCmd(cmd)
+		if err := chip.Step(); err != nil {
+			break
+		}
 	}
 }
 
-func (chip *Chip8) ProcessCmd(cmd uint16) {
-	var cmdStr string
+// Step fetches the command at PC and executes it.
+// On failure the configured OnError policy is applied and *ExecError is returned.
+func (chip *Chip8) Step() error {
+	pc := chip.Reg.PC
 
-	// for debug print purpose - save the current PC
+	if int(pc)+1 >= chip.MemorySize() {
+		err := &ExecError{Err: ErrMemoryOutOfBounds, PC: pc}
+		chip.applyPolicy(err)
+		return err
+	}
+
+	cmd := uint16(chip.Memory[int(pc)])<<8 + uint16(chip.Memory[int(pc+1)])
+
+	if err := chip.ProcessCmd(cmd); err != nil {
+		chip.applyPolicy(err.(*ExecError))
+		return err
+	}
+
+	return nil
+}
+
+// ProcessCmd executes single command as it would be located at current PC.
+// Returns *ExecError if command is invalid or can't be executed, machine state is not changed then.
+func (chip *Chip8) ProcessCmd(cmd uint16) error {
+	// for tracing and errors - save the current PC
 	curPC := chip.Reg.PC
-	// just debug print for now
-	switch cmd & 0xf000 {
-	case 0x0000:
-		if cmd == 0x00e0 {
-			cmdStr = "CLS"
-			chip.ClearScreen()
-		} else if cmd == 0x00ee {
-			cmdStr = "RET"
-			chip.Ret()
-		} else {
-			cmdStr = fmt.Sprintf("MCALL 0x%04x", cmd&0x0fff)
-		}
-	case 0x1000:
-		cmdStr = fmt.Sprintf("JMP 0x%04X", cmd&0x0fff)
-		chip.Jump(cmd & 0x0fff)
-	case 0x2000:
-		cmdStr = fmt.Sprintf("CALL 0x%04x", cmd&0x0fff)
-		chip.Call(cmd & 0x0fff)
-	case 0x3000:
-		cmdStr = fmt.Sprintf("SE  V%X, %02x", cmd&0x0f00>>8, cmd&0x00ff)
-		chip.SkipEqualVal(Register(cmd&0x0f00>>8), uint8(cmd&0x00ff))
-	case 0x4000:
-		cmdStr = fmt.Sprintf("SNE V%X, %02x", cmd&0x0f00>>8, cmd&0x00ff)
-		chip.SkipNotEqualVal(Register(cmd&0x0f00>>8), uint8(cmd&0x00ff))
-	case 0x5000:
-		cmdStr = fmt.Sprintf("SE  V%X, V%x", cmd&0x0f00>>8, cmd&0x00f0>>4)
-		chip.SkipEqualReg(Register(cmd&0x0f00>>8), Register(cmd&0x00f0>>4))
-	case 0x6000:
-		cmdStr = fmt.Sprintf("MOV V%X, %02x", cmd&0x0f00>>8, cmd&0x00ff)
-		chip.MovRegVal(Register(cmd&0x0f00>>8), cmd&0x00ff)
-	case 0x7000:
-		cmdStr = fmt.Sprintf("ADD V%x, %02x", cmd&0x0f00>>8, cmd&0x00ff)
-		chip.AddRegVal(Register(cmd&0x0f00>>8), uint8(cmd&0x00ff))
-	case 0x8000:
-		// ALU command
-		opCode := cmd & 0x000f
-		xr := Register(cmd & 0x0f00 >> 8)
-		yr := Register(cmd & 0x00f0 >> 4)
-
-		switch opCode {
-		case 0x00:
-			cmdStr = fmt.Sprintf("MOV V%x, V%x", xr, yr)
-			chip.MovRegReg(xr, yr)
-		case 0x01:
-			cmdStr = fmt.Sprintf("OR V%x, V%x", xr, yr)
-			chip.Or(xr, yr)
-		case 0x02:
-			cmdStr = fmt.Sprintf("AND V%x, V%x", xr, yr)
-			chip.And(xr, yr)
-		case 0x03:
-			cmdStr = fmt.Sprintf("XOR V%x, V%x", xr, yr)
-			chip.Xor(xr, yr)
-		case 0x04:
-			cmdStr = fmt.Sprintf("ADD V%x, V%x", xr, yr)
-			chip.AddRegReg(xr, yr)
-		case 0x05:
-			cmdStr = fmt.Sprintf("SUB V%x, V%x", xr, yr)
-			chip.SubRegReg(xr, yr)
-		case 0x06:
-			cmdStr = fmt.Sprintf("SHR V%x, V%x", xr, yr)
-			chip.ShiftR(xr, yr)
-		case 0x07:
-			cmdStr = fmt.Sprintf("SUBN V%x, V%x", xr, yr)
-			chip.SubNegRegReg(xr, yr)
-		case 0x0E:
-			cmdStr = fmt.Sprintf("SHL V%x, V%x", xr, yr)
-			chip.ShiftL(xr, yr)
-		default:
-			cmdStr = "NVO"
-		}
-	case 0x9000:
-		cmdStr = fmt.Sprintf("SNE V%x, V%x", cmd&0x0f00, cmd&0x00f0)
-		chip.SkipNotEqualReg(Register(cmd&0x0f00>>8), Register(cmd&0x00f0>>4))
-	case 0xa000:
-		cmdStr = fmt.Sprintf("MOV I, 0x%04x", cmd&0x0fff)
-		chip.MovRegVal(RegI, cmd&0x0fff)
-	case 0xb000:
-		cmdStr = fmt.Sprintf("JMPV 0x%04x", cmd&0x0fff)
-		chip.JumpV(cmd & 0x0fff)
-	case 0xc000:
-		cmdStr = fmt.Sprintf("RND V%X, %2X", cmd&0x0f00>>8, cmd&0x00ff)
-		chip.MovRegRnd(Register(cmd&0x0f00>>8), uint8(cmd&0x00ff))
-	case 0xd000:
-		cmdStr = fmt.Sprintf("DRAW %x, V%X, V%X ; (%d, %d)", cmd&0x000f, cmd&0x0f00>>8, cmd&0x00f0>>4, chip.getRegister(Register(cmd&0x0f00>>8)), chip.getRegister(Register(cmd&0x00f0>>4)))
-		xr := Register(cmd & 0x0f00 >> 8)
-		yr := Register(cmd & 0x00f0 >> 4)
-		chip.DisplayAt(xr, yr, int(cmd&0x000f))
-	case 0xe000:
-		xr := Register(cmd & 0x0f00 >> 8)
-
-		if cmd&0x00ff == 0x009E {
-			cmdStr = fmt.Sprintf("SK V%x", xr)
-			chip.SkipKeyPressedAtReg(xr)
-		} else if cmd&0x00ff == 0x00A1 {
-			cmdStr = fmt.Sprintf("SNK V%x", xr)
-			chip.SkipKeyNotPressedAtReg(xr)
+
+	var before RegisterSet
+	if chip.Tracer != nil {
+		before = chip.Reg
+	}
+
+	in := Decode(cmd)
+
+	var err error
+	if in.Size() == 4 {
+		// long operand follows the opcode
+		if int(curPC)+3 < chip.MemorySize() {
+			in.NNNN = uint16(chip.Memory[curPC+2])<<8 + uint16(chip.Memory[curPC+3])
 		} else {
-			cmdStr = "NVO"
+			err = ErrMemoryOutOfBounds
 		}
-	case 0xf000:
-		opCode := cmd & 0x00ff
-		switch opCode {
-		case 0x07:
-			cmdStr = fmt.Sprintf("MOV V%X, T0", cmd&0x0f00>>8)
-			chip.MovRegReg(Register(cmd&0x0f00>>8), RegT0)
-		case 0x0A:
-			// wait for key pressed
-			cmdStr = fmt.Sprintf("KEY V%x", cmd&0x0f00>>8)
-			chip.GetKeyReg(Register(cmd & 0x0f00 >> 8))
-		case 0x15:
-			cmdStr = fmt.Sprintf("MOV T0, V%X", cmd&0x0f00>>8)
-			chip.MovRegReg(RegT0, Register(cmd&0x0f00>>8))
-		case 0x18:
-			cmdStr = fmt.Sprintf("MOV T1, V%X", cmd&0x0f00>>8)
-			chip.MovRegReg(RegT1, Register(cmd&0x0f00>>8))
-		case 0x1E:
-			cmdStr = fmt.Sprintf("ADD I, V%X", cmd&0x0f00>>8)
-			chip.AddRegVal(RegI, uint8(chip.getRegister(Register(cmd&0x0f00>>8))))
-		case 0x29:
-			cmdStr = fmt.Sprintf("STC V%X", cmd&0x0f00>>8)
-			chip.SetCharReg(Register(cmd & 0x0f00 >> 8))
-		case 0x33:
-			cmdStr = fmt.Sprintf("BCD V%X", cmd&0x0f00>>8)
-			chip.BcdReg(Register(cmd & 0x0f00 >> 8))
-		case 0x55:
-			cmdStr = fmt.Sprintf("CAM V%X", cmd&0x0f00>>8)
-			chip.CopyRegToMem(Register(cmd & 0x0f00 >> 8))
-		case 0x65:
-			cmdStr = fmt.Sprintf("CAR V%X", cmd&0x0f00>>8)
-			chip.CopyMemToReg(Register(cmd & 0x0f00 >> 8))
-		default:
-			cmdStr = "NVO"
+	}
+	if err == nil {
+		chip.watch(int(curPC), int(in.Size()), WatchExec)
+		if chip.Profiler != nil {
+			chip.Profiler.record(chip, curPC)
 		}
-	default:
-		cmdStr = "NVO"
+		err = chip.execute(in)
 	}
 
-	fmt.Printf("\t%04x:\t%04x\t;%s\n", curPC, cmd, cmdStr)
+	if err != nil {
+		// failed instruction is handled by error policy
+		chip.watchHit = nil
+		err = &ExecError{Err: err, PC: curPC, Opcode: cmd}
+	} else {
+		chip.Cycles++
+	}
+
+	if chip.Tracer != nil {
+		chip.Tracer.Trace(&TraceEvent{PC: curPC, Opcode: cmd, Instruction: in, Before: before, After: chip.Reg, Err: err})
+	}
+
+	if chip.watchHit != nil {
+		chip.reportWatch(curPC, cmd, in)
+	}
+
+	return err
 }
 
 func (chip *Chip8) LoadRomFromFile(fileName string) (uint16, error) {
@@ -624,9 +872,12 @@ func (chip *Chip8) LoadRomFromFile(fileName string) (uint16, error) {
 	}
 
 	var size int64 = stats.Size()
+	if int(MEMORY_USER)+int(size) > chip.MemorySize() {
+		return 0, ErrMemoryOutOfBounds
+	}
 
 	bufr := bufio.NewReader(file)
-	_, err = bufr.Read(chip.Memory[MEMORY_USER:])
+	_, err = io.ReadFull(bufr, chip.Memory[MEMORY_USER:int64(MEMORY_USER)+size])
 
 	chip.RomSize = uint16(size)
 
@@ -634,6 +885,10 @@ func (chip *Chip8) LoadRomFromFile(fileName string) (uint16, error) {
 }
 
 func (chip *Chip8) LoadRomFromData(data []uint8) (uint16, error) {
+	if int(MEMORY_USER)+len(data) > chip.MemorySize() {
+		return 0, ErrMemoryOutOfBounds
+	}
+
 	for i, v := range data {
 		chip.Memory[int(MEMORY_USER)+i] = v
 	}
@@ -657,72 +912,90 @@ func (chip *Chip8) LoadFontFromData(data []uint8) (uint16, error) {
 	Both startPos and endPos are rounded to the 16 bytes, startPos to nearest below, endPos to nearest up
 */
 func (chip *Chip8) MemoryDump(startPos uint16, endPos uint16) {
+	chip.MemoryDumpTo(os.Stdout, startPos, endPos)
+}
+
+func (chip *Chip8) MemoryDumpTo(out io.Writer, startPos uint16, endPos uint16) {
 	startPos = startPos & 0xfff0
 	endPos = (endPos + 16) & 0xfff0
 
-	fmt.Printf("Memory dump %04x - %04x:\n", startPos, endPos)
-	fmt.Printf("\t00 01 02 03 04 05 06 07 08 09 0a 0b 0c 0d 0e 0f\n")
-	for startPos < endPos {
-		fmt.Printf("%04x", startPos)
-		fmt.Printf("\t%02x %02x %02x %02x", chip.Memory[startPos], chip.Memory[startPos+1], chip.Memory[startPos+2], chip.Memory[startPos+3])
-		fmt.Printf(" %02x %02x %02x %02x", chip.Memory[startPos+4], chip.Memory[startPos+5], chip.Memory[startPos+6], chip.Memory[startPos+7])
-		fmt.Printf(" %02x %02x %02x %02x", chip.Memory[startPos+8], chip.Memory[startPos+9], chip.Memory[startPos+10], chip.Memory[startPos+11])
-		fmt.Printf(" %02x %02x %02x %02x", chip.Memory[startPos+12], chip.Memory[startPos+13], chip.Memory[startPos+14], chip.Memory[startPos+15])
-		fmt.Println()
+	fmt.Fprintf(out, "Memory dump %04x - %04x:\n", startPos, endPos)
+	fmt.Fprintf(out, "\t00 01 02 03 04 05 06 07 08 09 0a 0b 0c 0d 0e 0f\n")
+	for startPos < endPos && int(startPos) < chip.MemorySize() {
+		fmt.Fprintf(out, "%04x", startPos)
+		fmt.Fprintf(out, "\t%02x %02x %02x %02x", chip.Memory[startPos], chip.Memory[startPos+1], chip.Memory[startPos+2], chip.Memory[startPos+3])
+		fmt.Fprintf(out, " %02x %02x %02x %02x", chip.Memory[startPos+4], chip.Memory[startPos+5], chip.Memory[startPos+6], chip.Memory[startPos+7])
+		fmt.Fprintf(out, " %02x %02x %02x %02x", chip.Memory[startPos+8], chip.Memory[startPos+9], chip.Memory[startPos+10], chip.Memory[startPos+11])
+		fmt.Fprintf(out, " %02x %02x %02x %02x", chip.Memory[startPos+12], chip.Memory[startPos+13], chip.Memory[startPos+14], chip.Memory[startPos+15])
+		fmt.Fprintln(out)
+		if startPos+16 < startPos {
+			// end of 64K memory
+			break
+		}
 		startPos += 16
 	}
 }
 
 func (chip *Chip8) DisplayDump() {
+	chip.DisplayDumpTo(os.Stdout)
+}
+
+func (chip *Chip8) DisplayDumpTo(out io.Writer) {
+	w, h := chip.DisplaySize()
+
 	drawHeader := func() {
-		print("   |")
-		for i := 0; i < DISPLAY_WIDTH; i++ {
-			print("-")
+		fmt.Fprint(out, "   |")
+		for i := 0; i < w; i++ {
+			fmt.Fprint(out, "-")
 		}
-		println("|")
+		fmt.Fprintln(out, "|")
 	}
 
-	print("    ")
-	for i := 0; i < DISPLAY_WIDTH; i++ {
+	fmt.Fprint(out, "    ")
+	for i := 0; i < w; i++ {
 		if i&0xf == 0 {
-			fmt.Printf("%X", i&0xf0>>4)
+			fmt.Fprintf(out, "%X", i&0xf0>>4)
 		} else {
-			print(" ")
+			fmt.Fprint(out, " ")
 		}
 	}
-	println()
+	fmt.Fprintln(out)
 
-	print("    ")
-	for i := 0; i < DISPLAY_WIDTH; i++ {
-		fmt.Printf("%X", i&0x0f)
+	fmt.Fprint(out, "    ")
+	for i := 0; i < w; i++ {
+		fmt.Fprintf(out, "%X", i&0x0f)
 	}
-	println()
+	fmt.Fprintln(out)
 
 	drawHeader()
-	for y := 0; y < DISPLAY_HEIGHT; y++ {
-		fmt.Printf("%2X |", y)
-		for x := 0; x < DISPLAY_WIDTH; x++ {
-			if chip.DisplayBuffer[x+y*DISPLAY_WIDTH] {
-				print("*")
+	for y := 0; y < h; y++ {
+		fmt.Fprintf(out, "%2X |", y)
+		for x := 0; x < w; x++ {
+			if chip.Pixel(x, y) {
+				fmt.Fprint(out, "*")
 			} else {
-				print(" ")
+				fmt.Fprint(out, " ")
 			}
 		}
-		println("|")
+		fmt.Fprintln(out, "|")
 	}
 	drawHeader()
 }
 
 func (chip *Chip8) RegistryDump() {
-	fmt.Println("Registry Dump:")
-	fmt.Printf("PC:\t%04x\n", chip.Reg.PC)
-	fmt.Printf("SP:\t%04x\n", chip.Reg.SP)
-	fmt.Printf("T0:\t%x02\n", chip.Reg.T0)
-	fmt.Printf("T1:\t%02x\n", chip.Reg.T1)
-	fmt.Printf(" I:\t%04x\n", chip.Reg.I)
-	fmt.Print(" V:\t")
+	chip.RegistryDumpTo(os.Stdout)
+}
+
+func (chip *Chip8) RegistryDumpTo(out io.Writer) {
+	fmt.Fprintln(out, "Registry Dump:")
+	fmt.Fprintf(out, "PC:\t%04x\n", chip.Reg.PC)
+	fmt.Fprintf(out, "SP:\t%04x\n", chip.Reg.SP)
+	fmt.Fprintf(out, "T0:\t%02x\n", chip.Reg.T0)
+	fmt.Fprintf(out, "T1:\t%02x\n", chip.Reg.T1)
+	fmt.Fprintf(out, " I:\t%04x\n", chip.Reg.I)
+	fmt.Fprint(out, " V:\t")
 	for i := 0; i < 16; i++ {
-		fmt.Printf("%02x ", chip.Reg.V[Register(i)])
+		fmt.Fprintf(out, "%02x ", chip.Reg.V[Register(i)])
 	}
-	fmt.Println()
+	fmt.Fprintln(out)
 }
@@ -40,9 +40,10 @@ func TestInit(t *testing.T) {
 	ch.Keyboard[15] = true
 
 	// set some rnd values for video buffer state
-	ch.DisplayBuffer[0] = true
-	ch.DisplayBuffer[123] = true
-	ch.DisplayBuffer[255] = true
+	ch.DisplayBuffer[0] = 1
+	ch.DisplayBuffer[123] = 1
+	ch.DisplayBuffer[255] = 1
+	ch.DisplayBuffer[300] = 0x03 // XO-CHIP both planes
 
 	// set some random state for memory
 	bytes, err := ch.LoadRomFromData([]uint8{1, 2, 3, 4, 5, 6, 7, 8, 9, 10})
@@ -63,9 +64,9 @@ func TestInit(t *testing.T) {
 	// make sure keyboard state reset
 	assert.Equal(t, [16]bool{}, ch.Keyboard)
 	// make sure video buffer state reset
-	assert.Equal(t, [chip8.DISPLAY_WIDTH * chip8.DISPLAY_HEIGHT]bool{}, ch.DisplayBuffer)
+	assert.Equal(t, [chip8.DISPLAY_WIDTH * chip8.DISPLAY_HEIGHT]uint8{}, ch.DisplayBuffer)
 	// make sure memory state reset, but skiping first 0x0200 bytes for now
-	assert.Equal(t, make([]uint8, chip8.MEMORY_SIZE)[chip8.MEMORY_USER:], ch.Memory[chip8.MEMORY_USER:])
+	assert.Equal(t, make([]uint8, len(ch.Memory))[chip8.MEMORY_USER:], ch.Memory[chip8.MEMORY_USER:])
 
 }
 
@@ -583,10 +584,10 @@ func TestShiftR(t *testing.T) {
 
 	for _, tc := range testTable_NotChip_8 {
 		t.Run(tc.Name, func(t *testing.T) {
-			ch.Init(chip8.XO_Chip)
+			ch.Init(chip8.Super_Chip_Modern)
 
 			expectedPC := uint16(0x0206)
-			// Non-Chip8 versions only work on X register (dst in this case)
+			// SCHIP versions only work on X register (dst in this case)
 			ch.MovRegVal(tc.RegDst, tc.RegVal)
 			ch.MovRegVal(tc.RegSrc, uint16(0xAA)) // some random value to test not changed reg
 			ch.ShiftR(tc.RegDst, tc.RegSrc)
@@ -653,7 +654,7 @@ func TestShiftL(t *testing.T) {
 
 	for _, tc := range testTable_NotChip_8 {
 		t.Run(tc.Name, func(t *testing.T) {
-			ch.Init(chip8.XO_Chip)
+			ch.Init(chip8.Super_Chip_Modern)
 
 			expectedPC := uint16(0x0206)
 
@@ -669,6 +670,57 @@ func TestShiftL(t *testing.T) {
 	}
 }
 
+func TestGetKeyReg(t *testing.T) {
+	ch := chip8.Chip8{}
+	ch.Init(chip8.Chip_8)
+
+	// nothing pressed - waiting
+	ch.GetKeyReg(chip8.RegV1)
+	assert.True(t, ch.State.WaitingForKey)
+	assert.Equal(t, uint16(0x0200), ch.Reg.PC)
+
+	// key pressed - still waiting for release
+	ch.Keyboard[0x07] = true
+	ch.GetKeyReg(chip8.RegV1)
+	ch.Keyboard[0x03] = true
+	ch.GetKeyReg(chip8.RegV1)
+	assert.True(t, ch.State.WaitingForKey)
+	assert.Equal(t, uint16(0x0200), ch.Reg.PC)
+
+	// other key released - latched one is still held
+	ch.Keyboard[0x03] = false
+	ch.GetKeyReg(chip8.RegV1)
+	assert.Equal(t, uint16(0x0200), ch.Reg.PC)
+
+	ch.Keyboard[0x07] = false
+	ch.GetKeyReg(chip8.RegV1)
+	assert.False(t, ch.State.WaitingForKey)
+	assert.Equal(t, uint8(0x07), ch.Reg.V[1])
+	assert.Equal(t, uint16(0x0202), ch.Reg.PC)
+}
+
+func TestSetKeyboard(t *testing.T) {
+	ch := chip8.Chip8{}
+	ch.Init(chip8.Chip_8)
+
+	ch.Keyboard[0x07] = true
+	ch.GetKeyReg(chip8.RegV1)
+	ch.GetKeyReg(chip8.RegV1)
+
+	// latched key is not held anymore - FX0A waits for a new press instead of completing
+	ch.SetKeyboard([0x10]bool{})
+	ch.GetKeyReg(chip8.RegV1)
+	assert.True(t, ch.State.WaitingForKey)
+	assert.Equal(t, uint16(0x0200), ch.Reg.PC)
+
+	ch.SetKeyboard([0x10]bool{0x05: true})
+	ch.GetKeyReg(chip8.RegV1)
+	ch.Keyboard[0x05] = false
+	ch.GetKeyReg(chip8.RegV1)
+	assert.False(t, ch.State.WaitingForKey)
+	assert.Equal(t, uint8(0x05), ch.Reg.V[1])
+}
+
 func TestBcdReg(t *testing.T) {
 
 	testTable := []struct {
@@ -0,0 +1,53 @@
+package chip8
+
+// CoverageKind is how the address was used at runtime
+type CoverageKind int
+
+const (
+	CoverageUntouched    CoverageKind = iota // never executed, read or written
+	CoverageData                             // read or written as data, never executed
+	CoverageExecuted                         // executed as instruction
+	CoverageSelfModified                     // executed and written at runtime
+)
+
+func (k CoverageKind) String() string {
+	switch k {
+	case CoverageData:
+		return "data"
+	case CoverageExecuted:
+		return "executed"
+	case CoverageSelfModified:
+		return "self-modified"
+	}
+	return "untouched"
+}
+
+// Coverage records memory accesses of executed instructions, set it to Chip8.Coverage before the run
+type Coverage struct {
+	Access       [MEMORY_SIZE_XO]WatchAccess // all the ways the address was accessed
+	Instructions [MEMORY_SIZE_XO]bool        // executed instruction starts
+}
+
+// mark records access to n bytes at adr
+func (c *Coverage) mark(adr, n int, access WatchAccess) {
+	if access&WatchExec != 0 && adr >= 0 && adr < len(c.Instructions) {
+		c.Instructions[adr] = true
+	}
+	for i := max(adr, 0); i < adr+n && i < len(c.Access); i++ {
+		c.Access[i] |= access
+	}
+}
+
+// Kind returns how the address was used
+func (c *Coverage) Kind(adr uint16) CoverageKind {
+	access := c.Access[adr]
+	switch {
+	case access&WatchExec != 0 && access&WatchWrite != 0:
+		return CoverageSelfModified
+	case access&WatchExec != 0:
+		return CoverageExecuted
+	case access != 0:
+		return CoverageData
+	}
+	return CoverageUntouched
+}
@@ -0,0 +1,43 @@
+package chip8_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brus-fabrika/chip8/chip8"
+)
+
+func TestCoverage(t *testing.T) {
+	ch := chip8.Chip8{}
+	ch.Init(chip8.Chip_8)
+	ch.Coverage = &chip8.Coverage{}
+
+	// MOV I, 0x020a; DRAW 1, V0, V0; BCD V0; JMP 0x0206; sprite
+	ch.LoadRomFromData([]uint8{0xa2, 0x0a, 0xd0, 0x01, 0xf0, 0x33, 0x12, 0x06, 0x00, 0x00, 0xf0})
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, ch.Step())
+	}
+
+	testCases := []struct {
+		adr  uint16
+		kind chip8.CoverageKind
+	}{
+		{0x0200, chip8.CoverageExecuted},
+		{0x0201, chip8.CoverageExecuted},
+		{0x0206, chip8.CoverageExecuted},
+		{0x0208, chip8.CoverageUntouched},
+		{0x020a, chip8.CoverageData},
+		{0x020b, chip8.CoverageData},
+		{0x020c, chip8.CoverageData},
+		{0x020d, chip8.CoverageUntouched},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.kind, ch.Coverage.Kind(tc.adr), "%04x", tc.adr)
+	}
+
+	assert.True(t, ch.Coverage.Instructions[0x0206])
+	assert.False(t, ch.Coverage.Instructions[0x0207])
+	assert.Equal(t, chip8.WatchRead|chip8.WatchWrite, ch.Coverage.Access[0x020a])
+	assert.Equal(t, "self-modified", chip8.CoverageSelfModified.String())
+}
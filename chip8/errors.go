@@ -0,0 +1,55 @@
+package chip8
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrInvalidOpcode     = errors.New("invalid opcode")
+	ErrStackOverflow     = errors.New("stack overflow")
+	ErrStackUnderflow    = errors.New("stack underflow")
+	ErrMemoryOutOfBounds = errors.New("memory out of bounds")
+)
+
+// ExecError is returned by Step and ProcessCmd, it wraps one of the Err* errors
+// with the address and opcode of the faulty instruction
+type ExecError struct {
+	Err    error
+	PC     uint16
+	Opcode uint16
+}
+
+func (e *ExecError) Error() string {
+	return fmt.Sprintf("%04x: %04x (%s): %v", e.PC, e.Opcode, Disassemble(e.Opcode), e.Err)
+}
+
+func (e *ExecError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorPolicy defines what machine does when instruction fails
+type ErrorPolicy int
+
+const (
+	PolicyHalt ErrorPolicy = iota // stop the machine (State.Running = false)
+	PolicySkip                    // ignore faulty instruction and continue with the next one
+	PolicyTrap                    // pause the machine at faulty instruction (State.Paused = true) and call OnTrap
+)
+
+// applyPolicy updates machine state according to configured error policy
+func (chip *Chip8) applyPolicy(err *ExecError) {
+	chip.State.Err = err
+
+	switch chip.OnError {
+	case PolicyHalt:
+		chip.State.Running = false
+	case PolicySkip:
+		chip.Reg.PC = err.PC + 2
+	case PolicyTrap:
+		chip.State.Paused = true
+		if chip.OnTrap != nil {
+			chip.OnTrap(err)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package chip8_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brus-fabrika/chip8/chip8"
+)
+
+func TestStep(t *testing.T) {
+	ch := chip8.Chip8{}
+	ch.Init(chip8.Chip_8)
+
+	// MOV V1, 0x0a; JMP 0x0200
+	ch.LoadRomFromData([]uint8{0x61, 0x0a, 0x12, 0x00})
+
+	assert.NoError(t, ch.Step())
+	assert.Equal(t, uint8(0x0a), ch.Reg.V[1])
+	assert.Equal(t, uint16(0x0202), ch.Reg.PC)
+
+	assert.NoError(t, ch.Step())
+	assert.Equal(t, uint16(0x0200), ch.Reg.PC)
+}
+
+func TestStepErrors(t *testing.T) {
+
+	testTable := []struct {
+		Name  string
+		Rom   []uint8
+		Setup func(ch *chip8.Chip8)
+		Err   error
+	}{
+		{Name: "InvalidOpcode", Rom: []uint8{0x81, 0x28}, Err: chip8.ErrInvalidOpcode},
+		{Name: "MachineCall", Rom: []uint8{0x01, 0x23}, Err: chip8.ErrInvalidOpcode},
+		{Name: "StackUnderflow", Rom: []uint8{0x00, 0xee}, Err: chip8.ErrStackUnderflow},
+		{Name: "DrawOutOfBounds", Rom: []uint8{0xd1, 0x2f}, Err: chip8.ErrMemoryOutOfBounds,
+			Setup: func(ch *chip8.Chip8) { ch.Reg.I = 0x0ff8 }},
+		{Name: "BcdOutOfBounds", Rom: []uint8{0xf1, 0x33}, Err: chip8.ErrMemoryOutOfBounds,
+			Setup: func(ch *chip8.Chip8) { ch.Reg.I = 0x0ffe }},
+		{Name: "CamOutOfBounds", Rom: []uint8{0xff, 0x55}, Err: chip8.ErrMemoryOutOfBounds,
+			Setup: func(ch *chip8.Chip8) { ch.Reg.I = 0x0ff8 }},
+		{Name: "CarOutOfBounds", Rom: []uint8{0xff, 0x65}, Err: chip8.ErrMemoryOutOfBounds,
+			Setup: func(ch *chip8.Chip8) { ch.Reg.I = 0xfff0 }},
+		{Name: "FetchOutOfBounds", Rom: []uint8{0x1f, 0xff}, Err: chip8.ErrMemoryOutOfBounds,
+			Setup: func(ch *chip8.Chip8) { ch.Step() }},
+	}
+
+	ch := chip8.Chip8{}
+
+	for _, tc := range testTable {
+		t.Run(tc.Name, func(t *testing.T) {
+			ch.Init(chip8.Chip_8)
+			ch.LoadRomFromData(tc.Rom)
+			if tc.Setup != nil {
+				tc.Setup(&ch)
+			}
+			pc := ch.Reg.PC
+
+			err := ch.Step()
+
+			var execErr *chip8.ExecError
+			if assert.ErrorIs(t, err, tc.Err) && assert.True(t, errors.As(err, &execErr)) {
+				assert.Equal(t, pc, execErr.PC)
+			}
+			// default policy halts the machine and keeps PC at faulty instruction
+			assert.False(t, ch.State.Running)
+			assert.Equal(t, pc, ch.Reg.PC)
+			assert.Equal(t, err, ch.State.Err)
+		})
+	}
+
+	depthCases := []struct {
+		Name     string
+		Ver      chip8.ChipVersion
+		MaxDepth int // 0 keeps the platform preset
+		Depth    int
+	}{
+		{Name: "VIP", Ver: chip8.Chip_8, Depth: 12},
+		{Name: "SCHIP", Ver: chip8.Super_Chip_Modern, Depth: 16},
+		{Name: "XO", Ver: chip8.XO_Chip, Depth: 16},
+		{Name: "Unlimited", Ver: chip8.Chip_8, MaxDepth: -1, Depth: 24},
+		{Name: "OverStackArea", Ver: chip8.Chip_8, MaxDepth: 100, Depth: 24},
+	}
+
+	for _, tc := range depthCases {
+		t.Run("StackOverflowDepth"+tc.Name, func(t *testing.T) {
+			ch.Init(tc.Ver)
+			if tc.MaxDepth != 0 {
+				ch.MaxStackDepth = tc.MaxDepth
+			}
+			ch.LoadRomFromData([]uint8{0x00, 0xe0, 0x22, 0x02}) // CLS; CALL 0x0202 - endless recursion
+			ch.Step()
+
+			depth := 0
+			for ch.Step() == nil {
+				depth++
+			}
+
+			assert.Equal(t, tc.Depth, depth)
+			assert.Equal(t, tc.Depth, ch.StackDepth())
+			var execErr *chip8.ExecError
+			if assert.ErrorAs(t, ch.State.Err, &execErr) {
+				assert.ErrorIs(t, execErr, chip8.ErrStackOverflow)
+				assert.Equal(t, uint16(0x0202), execErr.PC)
+			}
+		})
+	}
+}
+
+func TestErrorPolicy(t *testing.T) {
+	ch := chip8.Chip8{}
+
+	t.Run("Skip", func(t *testing.T) {
+		ch.Init(chip8.Chip_8)
+		ch.OnError = chip8.PolicySkip
+		ch.LoadRomFromData([]uint8{0xff, 0xff, 0x61, 0x0a})
+
+		assert.ErrorIs(t, ch.Step(), chip8.ErrInvalidOpcode)
+		assert.True(t, ch.State.Running)
+		assert.False(t, ch.State.Paused)
+		assert.Equal(t, uint16(0x0202), ch.Reg.PC)
+
+		assert.NoError(t, ch.Step())
+		assert.Equal(t, uint8(0x0a), ch.Reg.V[1])
+	})
+
+	t.Run("Trap", func(t *testing.T) {
+		ch.Init(chip8.Chip_8)
+		ch.OnError = chip8.PolicyTrap
+		ch.LoadRomFromData([]uint8{0xff, 0xff})
+
+		var trapped *chip8.ExecError
+		ch.OnTrap = func(err *chip8.ExecError) { trapped = err }
+
+		err := ch.Step()
+
+		assert.ErrorIs(t, err, chip8.ErrInvalidOpcode)
+		assert.True(t, ch.State.Running)
+		assert.True(t, ch.State.Paused)
+		assert.Equal(t, uint16(0x0200), ch.Reg.PC)
+		if assert.NotNil(t, trapped) {
+			assert.Equal(t, uint16(0xffff), trapped.Opcode)
+			assert.Equal(t, uint16(0x0200), trapped.PC)
+		}
+	})
+}
+
+func TestSkipKeyRegMasked(t *testing.T) {
+	ch := chip8.Chip8{}
+	ch.Init(chip8.Chip_8)
+
+	ch.Keyboard[0x0A] = true
+	ch.Reg.V[0x01] = 0xFA // only the lowest digit is used
+
+	assert.NotPanics(t, func() { ch.SkipKeyPressedAtReg(chip8.RegV1) })
+	assert.Equal(t, uint16(0x0204), ch.Reg.PC)
+
+	assert.NotPanics(t, func() { ch.SkipKeyNotPressedAtReg(chip8.RegV1) })
+	assert.Equal(t, uint16(0x0206), ch.Reg.PC)
+}
@@ -0,0 +1,356 @@
+package chip8
+
+import (
+	"fmt"
+	"strings"
+)
+
+type OpKind int
+
+const (
+	OpInvalid   OpKind = iota
+	OpSys              // 0NNN
+	OpCls              // 00E0
+	OpRet              // 00EE
+	OpJmp              // 1NNN
+	OpCall             // 2NNN
+	OpSeVal            // 3XNN
+	OpSneVal           // 4XNN
+	OpSeReg            // 5XY0
+	OpMovVal           // 6XNN
+	OpAddVal           // 7XNN
+	OpMovReg           // 8XY0
+	OpOr               // 8XY1
+	OpAnd              // 8XY2
+	OpXor              // 8XY3
+	OpAddReg           // 8XY4
+	OpSubReg           // 8XY5
+	OpShr              // 8XY6
+	OpSubNReg          // 8XY7
+	OpShl              // 8XYE
+	OpSneReg           // 9XY0
+	OpMovI             // ANNN
+	OpJmpV             // BNNN
+	OpRnd              // CXNN
+	OpDraw             // DXYN
+	OpSkp              // EX9E
+	OpSknp             // EXA1
+	OpMovRegT0         // FX07
+	OpKey              // FX0A
+	OpMovT0Reg         // FX15
+	OpMovT1Reg         // FX18
+	OpAddI             // FX1E
+	OpStc              // FX29
+	OpBcd              // FX33
+	OpCam              // FX55
+	OpCar              // FX65
+	OpScd              // 00CN
+	OpScr              // 00FB
+	OpScl              // 00FC
+	OpExit             // 00FD
+	OpLow              // 00FE
+	OpHigh             // 00FF
+	OpStcBig           // FX30
+	OpSaveFlags        // FX75
+	OpLoadFlags        // FX85
+	OpScu              // 00DN
+	OpSaveRange        // 5XY2
+	OpLoadRange        // 5XY3
+	OpMovILong         // F000 NNNN
+	OpPlane            // FN01
+	OpAudio            // F002
+	OpPitch            // FX3A
+)
+
+// Instruction is a decoded CHIP-8 command. All the operand fields are always
+// filled from the raw opcode, it is up to Op which of them are meaningful.
+type Instruction struct {
+	Op       OpKind
+	Opcode   uint16
+	X        Register
+	Y        Register
+	N        uint8
+	NN       uint8
+	NNN      uint16
+	NNNN     uint16 // XO-CHIP long address, the word following F000 opcode
+	Mnemonic string
+}
+
+// OpcodeInfo describes one entry of the instruction set.
+// Code is the human readable opcode pattern (as in documentation), Mask and Value
+// are used to match raw opcode: cmd & Mask == Value.
+// Syntax is the assembler template where {X}, {Y}, {N}, {NN}, {NNN} and {NNNN} are
+// substituted with instruction operands.
+type OpcodeInfo struct {
+	Op     OpKind
+	Code   string
+	Mask   uint16
+	Value  uint16
+	Syntax string
+	Func   string
+	Desc   string
+
+	Platforms []ChipVersion // platforms supporting the instruction, nil for all of them
+
+	exec func(chip *Chip8, in Instruction) error
+}
+
+// Supports checks if instruction is available on the platform
+func (info *OpcodeInfo) Supports(ver ChipVersion) bool {
+	if info.Platforms == nil {
+		return true
+	}
+
+	for _, v := range info.Platforms {
+		if v == ver {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Mnemonic returns the first word of the syntax template
+func (info *OpcodeInfo) Mnemonic() string {
+	mnemonic, _, _ := strings.Cut(info.Syntax, " ")
+	return mnemonic
+}
+
+// Opcodes is the instruction set table, the single source for decoding, execution and disassembly.
+// Order matters: more specific patterns must go before the generic ones (00E0 before 0NNN).
+var Opcodes = []OpcodeInfo{
+	{Op: OpCls, Code: "00E0", Mask: 0xffff, Value: 0x00e0, Syntax: "CLS", Func: "ClearScreen()", Desc: "Clear screen",
+		exec: func(chip *Chip8, in Instruction) error { chip.ClearScreen(); return nil }},
+	{Op: OpRet, Code: "00EE", Mask: 0xffff, Value: 0x00ee, Syntax: "RET", Func: "Ret()", Desc: "Return from subroutine call",
+		exec: func(chip *Chip8, in Instruction) error { return chip.Ret() }},
+	{Op: OpScd, Code: "00CN", Mask: 0xfff0, Value: 0x00c0, Syntax: "SCD {N}", Func: "ScrollDown(N)", Desc: "Scroll display N pixels down", Platforms: schipPlatforms,
+		exec: func(chip *Chip8, in Instruction) error { chip.ScrollDown(int(in.N)); return nil }},
+	{Op: OpScu, Code: "00DN", Mask: 0xfff0, Value: 0x00d0, Syntax: "SCU {N}", Func: "ScrollUp(N)", Desc: "Scroll display N pixels up", Platforms: xoPlatforms,
+		exec: func(chip *Chip8, in Instruction) error { chip.ScrollUp(int(in.N)); return nil }},
+	{Op: OpScr, Code: "00FB", Mask: 0xffff, Value: 0x00fb, Syntax: "SCR", Func: "ScrollRight()", Desc: "Scroll display 4 pixels right", Platforms: schipPlatforms,
+		exec: func(chip *Chip8, in Instruction) error { chip.ScrollRight(); return nil }},
+	{Op: OpScl, Code: "00FC", Mask: 0xffff, Value: 0x00fc, Syntax: "SCL", Func: "ScrollLeft()", Desc: "Scroll display 4 pixels left", Platforms: schipPlatforms,
+		exec: func(chip *Chip8, in Instruction) error { chip.ScrollLeft(); return nil }},
+	{Op: OpExit, Code: "00FD", Mask: 0xffff, Value: 0x00fd, Syntax: "EXIT", Func: "Exit()", Desc: "Stop the interpreter", Platforms: schipPlatforms,
+		exec: func(chip *Chip8, in Instruction) error { chip.Exit(); return nil }},
+	{Op: OpLow, Code: "00FE", Mask: 0xffff, Value: 0x00fe, Syntax: "LOW", Func: "SetHires(false)", Desc: "Switch to lores 64x32 mode", Platforms: schipPlatforms,
+		exec: func(chip *Chip8, in Instruction) error { chip.SetHires(false); return nil }},
+	{Op: OpHigh, Code: "00FF", Mask: 0xffff, Value: 0x00ff, Syntax: "HIGH", Func: "SetHires(true)", Desc: "Switch to hires 128x64 mode", Platforms: schipPlatforms,
+		exec: func(chip *Chip8, in Instruction) error { chip.SetHires(true); return nil }},
+	{Op: OpSys, Code: "0NNN", Mask: 0xf000, Value: 0x0000, Syntax: "MCALL {NNN}", Func: "", Desc: "Machine (OS) subroutine call"},
+	{Op: OpJmp, Code: "1NNN", Mask: 0xf000, Value: 0x1000, Syntax: "JMP {NNN}", Func: "Jump(NNN)", Desc: "Unconditional jump to address",
+		exec: func(chip *Chip8, in Instruction) error { chip.Jump(in.NNN); return nil }},
+	{Op: OpCall, Code: "2NNN", Mask: 0xf000, Value: 0x2000, Syntax: "CALL {NNN}", Func: "Call(NNN)", Desc: "Subroutine call",
+		exec: func(chip *Chip8, in Instruction) error { return chip.Call(in.NNN) }},
+	{Op: OpSeVal, Code: "3XNN", Mask: 0xf000, Value: 0x3000, Syntax: "SE V{X}, {NN}", Func: "SkipEqualVal(VX, NN)", Desc: "Skip next command if VX == NN",
+		exec: func(chip *Chip8, in Instruction) error { chip.SkipEqualVal(in.X, in.NN); return nil }},
+	{Op: OpSneVal, Code: "4XNN", Mask: 0xf000, Value: 0x4000, Syntax: "SNE V{X}, {NN}", Func: "SkipNotEqualVal(VX, NN)", Desc: "Skip next command if VX != NN",
+		exec: func(chip *Chip8, in Instruction) error { chip.SkipNotEqualVal(in.X, in.NN); return nil }},
+	{Op: OpSeReg, Code: "5XY0", Mask: 0xf00f, Value: 0x5000, Syntax: "SE V{X}, V{Y}", Func: "SkipEqualReg(VX, VY)", Desc: "Skip next command if VX == VY",
+		exec: func(chip *Chip8, in Instruction) error { chip.SkipEqualReg(in.X, in.Y); return nil }},
+	{Op: OpSaveRange, Code: "5XY2", Mask: 0xf00f, Value: 0x5002, Syntax: "CAMR V{X}, V{Y}", Func: "CopyRegRangeToMem(VX, VY)", Desc: "Set MI = VX:VY (I not updated)", Platforms: xoPlatforms,
+		exec: func(chip *Chip8, in Instruction) error { return chip.CopyRegRangeToMem(in.X, in.Y) }},
+	{Op: OpLoadRange, Code: "5XY3", Mask: 0xf00f, Value: 0x5003, Syntax: "CARR V{X}, V{Y}", Func: "CopyMemToRegRange(VX, VY)", Desc: "Set VX:VY = MI (I not updated)", Platforms: xoPlatforms,
+		exec: func(chip *Chip8, in Instruction) error { return chip.CopyMemToRegRange(in.X, in.Y) }},
+	{Op: OpMovVal, Code: "6XNN", Mask: 0xf000, Value: 0x6000, Syntax: "MOV V{X}, {NN}", Func: "MovRegVal(VX, NN)", Desc: "Set VX = NN",
+		exec: func(chip *Chip8, in Instruction) error { chip.MovRegVal(in.X, uint16(in.NN)); return nil }},
+	{Op: OpAddVal, Code: "7XNN", Mask: 0xf000, Value: 0x7000, Syntax: "ADD V{X}, {NN}", Func: "AddRegVal(VX, NN)", Desc: "Set VX = VX + NN",
+		exec: func(chip *Chip8, in Instruction) error { chip.AddRegVal(in.X, in.NN); return nil }},
+	{Op: OpMovReg, Code: "8XY0", Mask: 0xf00f, Value: 0x8000, Syntax: "MOV V{X}, V{Y}", Func: "MovRegReg(VX, VY)", Desc: "Set VX = VY",
+		exec: func(chip *Chip8, in Instruction) error { chip.MovRegReg(in.X, in.Y); return nil }},
+	{Op: OpOr, Code: "8XY1", Mask: 0xf00f, Value: 0x8001, Syntax: "OR V{X}, V{Y}", Func: "Or(VX, VY)", Desc: "Set VX = VX OR VY (VF reset with vfreset quirk)",
+		exec: func(chip *Chip8, in Instruction) error { chip.Or(in.X, in.Y); return nil }},
+	{Op: OpAnd, Code: "8XY2", Mask: 0xf00f, Value: 0x8002, Syntax: "AND V{X}, V{Y}", Func: "And(VX, VY)", Desc: "Set VX = VX AND VY (VF reset with vfreset quirk)",
+		exec: func(chip *Chip8, in Instruction) error { chip.And(in.X, in.Y); return nil }},
+	{Op: OpXor, Code: "8XY3", Mask: 0xf00f, Value: 0x8003, Syntax: "XOR V{X}, V{Y}", Func: "Xor(VX, VY)", Desc: "Set VX = VX XOR VY (VF reset with vfreset quirk)",
+		exec: func(chip *Chip8, in Instruction) error { chip.Xor(in.X, in.Y); return nil }},
+	{Op: OpAddReg, Code: "8XY4", Mask: 0xf00f, Value: 0x8004, Syntax: "ADD V{X}, V{Y}", Func: "AddRegReg(VX, VY)", Desc: "Set VX = VX + VY (VF mod)",
+		exec: func(chip *Chip8, in Instruction) error { chip.AddRegReg(in.X, in.Y); return nil }},
+	{Op: OpSubReg, Code: "8XY5", Mask: 0xf00f, Value: 0x8005, Syntax: "SUB V{X}, V{Y}", Func: "SubRegReg(VX, VY)", Desc: "Set VX = VX - VY (VF mod)",
+		exec: func(chip *Chip8, in Instruction) error { chip.SubRegReg(in.X, in.Y); return nil }},
+	{Op: OpShr, Code: "8XY6", Mask: 0xf00f, Value: 0x8006, Syntax: "SHR V{X}, V{Y}", Func: "ShiftR(VX, VY)", Desc: "Set VX = VX>>1, VY>>1 with shift quirk (VF mod)",
+		exec: func(chip *Chip8, in Instruction) error { chip.ShiftR(in.X, in.Y); return nil }},
+	{Op: OpSubNReg, Code: "8XY7", Mask: 0xf00f, Value: 0x8007, Syntax: "SUBN V{X}, V{Y}", Func: "SubNegRegReg(VX, VY)", Desc: "Set VX = VY - VX (VF mod)",
+		exec: func(chip *Chip8, in Instruction) error { chip.SubNegRegReg(in.X, in.Y); return nil }},
+	{Op: OpShl, Code: "8XYE", Mask: 0xf00f, Value: 0x800e, Syntax: "SHL V{X}, V{Y}", Func: "ShiftL(VX, VY)", Desc: "Set VX = VX<<1, VY<<1 with shift quirk (VF mod)",
+		exec: func(chip *Chip8, in Instruction) error { chip.ShiftL(in.X, in.Y); return nil }},
+	{Op: OpSneReg, Code: "9XY0", Mask: 0xf00f, Value: 0x9000, Syntax: "SNE V{X}, V{Y}", Func: "SkipNotEqualReg(VX, VY)", Desc: "Skip next command if VX != VY",
+		exec: func(chip *Chip8, in Instruction) error { chip.SkipNotEqualReg(in.X, in.Y); return nil }},
+	{Op: OpMovI, Code: "ANNN", Mask: 0xf000, Value: 0xa000, Syntax: "MOV I, {NNN}", Func: "MovRegVal(I, NNN)", Desc: "Set I = NNN",
+		exec: func(chip *Chip8, in Instruction) error { chip.MovRegVal(RegI, in.NNN); return nil }},
+	{Op: OpJmpV, Code: "BNNN", Mask: 0xf000, Value: 0xb000, Syntax: "JMPV {NNN}", Func: "JumpV(NNN)", Desc: "Unconditional jump to (V0 + address), (VX + address) with jump quirk",
+		exec: func(chip *Chip8, in Instruction) error { chip.JumpV(in.NNN); return nil }},
+	{Op: OpRnd, Code: "CXNN", Mask: 0xf000, Value: 0xc000, Syntax: "RND V{X}, {NN}", Func: "MovRegRnd(VX, NN)", Desc: "Set VX = Rnd with NN as mask",
+		exec: func(chip *Chip8, in Instruction) error { chip.MovRegRnd(in.X, in.NN); return nil }},
+	{Op: OpDraw, Code: "DXYN", Mask: 0xf000, Value: 0xd000, Syntax: "DRAW {N}, V{X}, V{Y}", Func: "DisplayAt(VX, VY, N)", Desc: "Draw N bytes sprite from MI at pos VX,VY (VF = collision), N = 0 draws 16x16 sprite on SCHIP",
+		exec: func(chip *Chip8, in Instruction) error { return chip.DisplayAt(in.X, in.Y, int(in.N)) }},
+	{Op: OpSkp, Code: "EX9E", Mask: 0xf0ff, Value: 0xe09e, Syntax: "SK V{X}", Func: "SkipKeyPressedAtReg(VX)", Desc: "Skip next command if key VX is pressed",
+		exec: func(chip *Chip8, in Instruction) error { chip.SkipKeyPressedAtReg(in.X); return nil }},
+	{Op: OpSknp, Code: "EXA1", Mask: 0xf0ff, Value: 0xe0a1, Syntax: "SNK V{X}", Func: "SkipKeyNotPressedAtReg(VX)", Desc: "Skip next command if key VX is not pressed",
+		exec: func(chip *Chip8, in Instruction) error { chip.SkipKeyNotPressedAtReg(in.X); return nil }},
+	{Op: OpMovILong, Code: "F000 NNNN", Mask: 0xffff, Value: 0xf000, Syntax: "MOVL I, {NNNN}", Func: "MovILong(NNNN)", Desc: "Set I = NNNN (4 bytes long instruction)", Platforms: xoPlatforms,
+		exec: func(chip *Chip8, in Instruction) error { chip.MovILong(in.NNNN); return nil }},
+	{Op: OpPlane, Code: "FN01", Mask: 0xf0ff, Value: 0xf001, Syntax: "PLANE {X}", Func: "SetPlanes(N)", Desc: "Select drawing planes by mask N", Platforms: xoPlatforms,
+		exec: func(chip *Chip8, in Instruction) error { chip.SetPlanes(uint8(in.X)); return nil }},
+	{Op: OpAudio, Code: "F002", Mask: 0xffff, Value: 0xf002, Syntax: "AUDIO", Func: "LoadAudioPattern()", Desc: "Set audio pattern = 16 bytes from MI", Platforms: xoPlatforms,
+		exec: func(chip *Chip8, in Instruction) error { return chip.LoadAudioPattern() }},
+	{Op: OpMovRegT0, Code: "FX07", Mask: 0xf0ff, Value: 0xf007, Syntax: "MOV V{X}, T0", Func: "MovRegReg(VX, T0)", Desc: "Set VX = T0 current timer value",
+		exec: func(chip *Chip8, in Instruction) error { chip.MovRegReg(in.X, RegT0); return nil }},
+	{Op: OpKey, Code: "FX0A", Mask: 0xf0ff, Value: 0xf00a, Syntax: "KEY V{X}", Func: "GetKeyReg(VX)", Desc: "Wait for key press and release, set VX = Hex Key digit",
+		exec: func(chip *Chip8, in Instruction) error { chip.GetKeyReg(in.X); return nil }},
+	{Op: OpMovT0Reg, Code: "FX15", Mask: 0xf0ff, Value: 0xf015, Syntax: "MOV T0, V{X}", Func: "MovRegReg(T0, VX)", Desc: "Set T0 = VX",
+		exec: func(chip *Chip8, in Instruction) error { chip.MovRegReg(RegT0, in.X); return nil }},
+	{Op: OpMovT1Reg, Code: "FX18", Mask: 0xf0ff, Value: 0xf018, Syntax: "MOV T1, V{X}", Func: "MovRegReg(T1, VX)", Desc: "Set T1 = VX",
+		exec: func(chip *Chip8, in Instruction) error { chip.MovRegReg(RegT1, in.X); return nil }},
+	{Op: OpAddI, Code: "FX1E", Mask: 0xf0ff, Value: 0xf01e, Syntax: "ADD I, V{X}", Func: "AddIReg(VX)", Desc: "Set I = I + VX (VF mod with addi quirk)",
+		exec: func(chip *Chip8, in Instruction) error { chip.AddIReg(in.X); return nil }},
+	{Op: OpStc, Code: "FX29", Mask: 0xf0ff, Value: 0xf029, Syntax: "STC V{X}", Func: "SetCharReg(VX)", Desc: "Set I = address of font char for VX (LSD)",
+		exec: func(chip *Chip8, in Instruction) error { chip.SetCharReg(in.X); return nil }},
+	{Op: OpStcBig, Code: "FX30", Mask: 0xf0ff, Value: 0xf030, Syntax: "STCH V{X}", Func: "SetBigCharReg(VX)", Desc: "Set I = address of big font char for VX (LSD)", Platforms: schipPlatforms,
+		exec: func(chip *Chip8, in Instruction) error { chip.SetBigCharReg(in.X); return nil }},
+	{Op: OpPitch, Code: "FX3A", Mask: 0xf0ff, Value: 0xf03a, Syntax: "PITCH V{X}", Func: "SetPitchReg(VX)", Desc: "Set audio pattern pitch = VX", Platforms: xoPlatforms,
+		exec: func(chip *Chip8, in Instruction) error { chip.SetPitchReg(in.X); return nil }},
+	{Op: OpBcd, Code: "FX33", Mask: 0xf0ff, Value: 0xf033, Syntax: "BCD V{X}", Func: "BcdReg(VX)", Desc: "Set MI = 3 dec digit of VX (I not updated)",
+		exec: func(chip *Chip8, in Instruction) error { return chip.BcdReg(in.X) }},
+	{Op: OpCam, Code: "FX55", Mask: 0xf0ff, Value: 0xf055, Syntax: "CAM V{X}", Func: "CopyRegToMem(VX)", Desc: "Set MI = V0:VX (I = I + X + 1 with loadstore quirk)",
+		exec: func(chip *Chip8, in Instruction) error { return chip.CopyRegToMem(in.X) }},
+	{Op: OpCar, Code: "FX65", Mask: 0xf0ff, Value: 0xf065, Syntax: "CAR V{X}", Func: "CopyMemToReg(VX)", Desc: "Set V0:VX = MI (I = I + X + 1 with loadstore quirk)",
+		exec: func(chip *Chip8, in Instruction) error { return chip.CopyMemToReg(in.X) }},
+	{Op: OpSaveFlags, Code: "FX75", Mask: 0xf0ff, Value: 0xf075, Syntax: "SAVEF V{X}", Func: "SaveFlagsReg(VX)", Desc: "Set RPL flags = V0:VX", Platforms: schipPlatforms,
+		exec: func(chip *Chip8, in Instruction) error { chip.SaveFlagsReg(in.X); return nil }},
+	{Op: OpLoadFlags, Code: "FX85", Mask: 0xf0ff, Value: 0xf085, Syntax: "LOADF V{X}", Func: "LoadFlagsReg(VX)", Desc: "Set V0:VX = RPL flags", Platforms: schipPlatforms,
+		exec: func(chip *Chip8, in Instruction) error { chip.LoadFlagsReg(in.X); return nil }},
+}
+
+// opcodeByKind gives direct access to the Opcodes entry by its OpKind
+var opcodeByKind = map[OpKind]*OpcodeInfo{}
+
+func init() {
+	for i := range Opcodes {
+		opcodeByKind[Opcodes[i].Op] = &Opcodes[i]
+	}
+}
+
+// Decode splits the raw opcode into operands and finds its instruction set entry.
+// Unknown opcodes are decoded as OpInvalid with "NVO" mnemonic.
+func Decode(cmd uint16) Instruction {
+	in := Instruction{
+		Op:       OpInvalid,
+		Opcode:   cmd,
+		X:        Register(cmd & 0x0f00 >> 8),
+		Y:        Register(cmd & 0x00f0 >> 4),
+		N:        uint8(cmd & 0x000f),
+		NN:       uint8(cmd & 0x00ff),
+		NNN:      cmd & 0x0fff,
+		Mnemonic: "NVO",
+	}
+
+	for i := range Opcodes {
+		if cmd&Opcodes[i].Mask == Opcodes[i].Value {
+			in.Op = Opcodes[i].Op
+			in.Mnemonic = Opcodes[i].Mnemonic()
+			break
+		}
+	}
+
+	return in
+}
+
+// Info returns the instruction set entry of decoded instruction, nil for invalid one
+func (in Instruction) Info() *OpcodeInfo {
+	return opcodeByKind[in.Op]
+}
+
+// String returns the instruction in assembler syntax, i.e. "MOV V1, 0a"
+func (in Instruction) String() string {
+	info := in.Info()
+	if info == nil {
+		return in.Mnemonic
+	}
+
+	var sb strings.Builder
+	syntax := info.Syntax
+	for {
+		start := strings.IndexByte(syntax, '{')
+		if start < 0 {
+			sb.WriteString(syntax)
+			break
+		}
+		end := strings.IndexByte(syntax[start:], '}') + start
+
+		sb.WriteString(syntax[:start])
+		switch syntax[start+1 : end] {
+		case "X":
+			fmt.Fprintf(&sb, "%X", uint8(in.X))
+		case "Y":
+			fmt.Fprintf(&sb, "%X", uint8(in.Y))
+		case "N":
+			fmt.Fprintf(&sb, "%x", in.N)
+		case "NN":
+			fmt.Fprintf(&sb, "%02x", in.NN)
+		case "NNN":
+			fmt.Fprintf(&sb, "0x%04x", in.NNN)
+		case "NNNN":
+			fmt.Fprintf(&sb, "0x%04x", in.NNNN)
+		}
+		syntax = syntax[end+1:]
+	}
+
+	return sb.String()
+}
+
+// DecodeAt decodes the instruction at address, including the long address operand of F000 NNNN.
+// Address past the end of memory and F000 without its operand at the end are decoded as OpInvalid.
+func DecodeAt(mem []uint8, adr int) Instruction {
+	if adr < 0 || adr+1 >= len(mem) {
+		return Instruction{Op: OpInvalid, Mnemonic: "NVO"}
+	}
+
+	in := Decode(uint16(mem[adr])<<8 + uint16(mem[adr+1]))
+	if in.Size() == 4 {
+		if adr+3 >= len(mem) {
+			in.Op, in.Mnemonic = OpInvalid, "NVO"
+			return in
+		}
+		in.NNNN = uint16(mem[adr+2])<<8 + uint16(mem[adr+3])
+	}
+
+	return in
+}
+
+// Disassemble decodes the opcode and returns it in assembler syntax
+func Disassemble(cmd uint16) string {
+	return Decode(cmd).String()
+}
+
+// execute runs already decoded instruction, instructions without implementation
+// or not supported by the platform are invalid
+func (chip *Chip8) execute(in Instruction) error {
+	info := in.Info()
+	if info == nil || info.exec == nil || !info.Supports(chip.Ver) {
+		return ErrInvalidOpcode
+	}
+
+	return info.exec(chip, in)
+}
+
+// CommandTable renders the instruction set as markdown table (the one in ReadMe.md)
+func CommandTable() string {
+	var sb strings.Builder
+
+	sb.WriteString("| Code | Syntax | Impl Function | Description | Platforms |\n")
+	sb.WriteString("|------|--------|---------------|-------------|-----------|\n")
+	for _, info := range Opcodes {
+		platforms := "all"
+		if info.Platforms != nil {
+			names := make([]string, len(info.Platforms))
+			for i, ver := range info.Platforms {
+				names[i] = ver.String()
+			}
+			platforms = strings.Join(names, ", ")
+		}
+		fmt.Fprintf(&sb, "| %s | %s | %s | %s | %s |\n", info.Code, info.Syntax, info.Func, info.Desc, platforms)
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,119 @@
+package chip8_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brus-fabrika/chip8/chip8"
+)
+
+func TestDecode(t *testing.T) {
+
+	testTable := []struct {
+		Name     string
+		Cmd      uint16
+		Op       chip8.OpKind
+		Mnemonic string
+		Asm      string
+	}{
+		{Name: "CLS", Cmd: 0x00e0, Op: chip8.OpCls, Mnemonic: "CLS", Asm: "CLS"},
+		{Name: "RET", Cmd: 0x00ee, Op: chip8.OpRet, Mnemonic: "RET", Asm: "RET"},
+		{Name: "MCALL", Cmd: 0x0123, Op: chip8.OpSys, Mnemonic: "MCALL", Asm: "MCALL 0x0123"},
+		{Name: "JMP", Cmd: 0x1abc, Op: chip8.OpJmp, Mnemonic: "JMP", Asm: "JMP 0x0abc"},
+		{Name: "SE_Val", Cmd: 0x3a0f, Op: chip8.OpSeVal, Mnemonic: "SE", Asm: "SE VA, 0f"},
+		{Name: "SE_Reg", Cmd: 0x5120, Op: chip8.OpSeReg, Mnemonic: "SE", Asm: "SE V1, V2"},
+		{Name: "MOV_Val", Cmd: 0x610a, Op: chip8.OpMovVal, Mnemonic: "MOV", Asm: "MOV V1, 0a"},
+		{Name: "SHL", Cmd: 0x83ee, Op: chip8.OpShl, Mnemonic: "SHL", Asm: "SHL V3, VE"},
+		{Name: "SNE_Reg", Cmd: 0x9ab0, Op: chip8.OpSneReg, Mnemonic: "SNE", Asm: "SNE VA, VB"},
+		{Name: "MOV_I", Cmd: 0xa220, Op: chip8.OpMovI, Mnemonic: "MOV", Asm: "MOV I, 0x0220"},
+		{Name: "DRAW", Cmd: 0xd125, Op: chip8.OpDraw, Mnemonic: "DRAW", Asm: "DRAW 5, V1, V2"},
+		{Name: "SNK", Cmd: 0xe4a1, Op: chip8.OpSknp, Mnemonic: "SNK", Asm: "SNK V4"},
+		{Name: "MOV_T0", Cmd: 0xf315, Op: chip8.OpMovT0Reg, Mnemonic: "MOV", Asm: "MOV T0, V3"},
+		{Name: "CAM", Cmd: 0xf355, Op: chip8.OpCam, Mnemonic: "CAM", Asm: "CAM V3"},
+		{Name: "NVO_ALU", Cmd: 0x8128, Op: chip8.OpInvalid, Mnemonic: "NVO", Asm: "NVO"},
+		{Name: "NVO_SE", Cmd: 0x5121, Op: chip8.OpInvalid, Mnemonic: "NVO", Asm: "NVO"},
+		{Name: "NVO_F", Cmd: 0xf1ff, Op: chip8.OpInvalid, Mnemonic: "NVO", Asm: "NVO"},
+	}
+
+	for _, tc := range testTable {
+		t.Run(tc.Name, func(t *testing.T) {
+			in := chip8.Decode(tc.Cmd)
+
+			assert.Equal(t, tc.Op, in.Op)
+			assert.Equal(t, tc.Cmd, in.Opcode)
+			assert.Equal(t, tc.Mnemonic, in.Mnemonic)
+			assert.Equal(t, tc.Asm, in.String())
+			assert.Equal(t, tc.Asm, chip8.Disassemble(tc.Cmd))
+		})
+	}
+
+	t.Run("Operands", func(t *testing.T) {
+		in := chip8.Decode(0xd12f)
+
+		assert.Equal(t, chip8.RegV1, in.X)
+		assert.Equal(t, chip8.RegV2, in.Y)
+		assert.Equal(t, uint8(0x0f), in.N)
+		assert.Equal(t, uint8(0x2f), in.NN)
+		assert.Equal(t, uint16(0x012f), in.NNN)
+	})
+}
+
+func TestDecodeAt(t *testing.T) {
+	// CLS; MOV I, 0x1234 (F000 NNNN)
+	mem := []uint8{0x00, 0xe0, 0xf0, 0x00, 0x12, 0x34}
+
+	tests := []struct {
+		name string
+		mem  []uint8
+		adr  int
+		op   chip8.OpKind
+		size uint16
+		nnnn uint16
+	}{
+		{"Short", mem, 0, chip8.OpCls, 2, 0},
+		{"Long", mem, 2, chip8.OpMovILong, 4, 0x1234},
+		{"LongTruncated", mem[:5], 2, chip8.OpInvalid, 2, 0},
+		{"HalfOpcode", mem[:5], 4, chip8.OpInvalid, 2, 0},
+		{"PastEnd", mem, 6, chip8.OpInvalid, 2, 0},
+		{"Negative", mem, -1, chip8.OpInvalid, 2, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := chip8.DecodeAt(tt.mem, tt.adr)
+			assert.Equal(t, tt.op, in.Op)
+			assert.Equal(t, tt.size, in.Size())
+			assert.Equal(t, tt.nnnn, in.NNNN)
+			if tt.op == chip8.OpInvalid {
+				assert.Nil(t, in.Info())
+				assert.Equal(t, "NVO", in.String())
+			}
+		})
+	}
+}
+
+func TestOpcodesTable(t *testing.T) {
+	seen := map[chip8.OpKind]bool{}
+
+	for _, info := range chip8.Opcodes {
+		// every entry should be decoded back to itself
+		in := chip8.Decode(info.Value)
+		assert.Equal(t, info.Op, in.Op, info.Code)
+		assert.Equal(t, info.Mnemonic(), in.Mnemonic, info.Code)
+
+		assert.False(t, seen[info.Op], "duplicated entry %s", info.Code)
+		seen[info.Op] = true
+	}
+}
+
+func TestReadMeCommandTable(t *testing.T) {
+	data, err := os.ReadFile("../ReadMe.md")
+
+	if assert.NoError(t, err) {
+		readme := strings.ReplaceAll(string(data), "\r\n", "\n")
+		assert.Contains(t, readme, chip8.CommandTable(), "ReadMe.md commands table is out of date, regenerate it with chip8.CommandTable()")
+	}
+}
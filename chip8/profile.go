@@ -0,0 +1,257 @@
+package chip8
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// Profiler counts executed instructions per PC and per call stack path, the result is written as pprof profile:
+//
+//	go tool pprof -top -addresses profile.pb.gz
+//	go tool pprof -http=: profile.pb.gz
+//
+// Subroutines are named by their entry address (sub_XXXX) or by Labels, the bottom frame is main.
+type Profiler struct {
+	InstructionsPerSec int               // for the emulated time values, DEFAULT_CYCLES_PER_FRAME * FRAME_RATE if not set
+	Labels             map[uint16]string // optional subroutine names by the entry address
+
+	samples map[string]*profileSample // by stack path: PC and the return addresses
+	counts  map[uint16]int64
+	total   int64
+	start   time.Time
+	key     []byte // reused stack path key buffer
+}
+
+type profileFrame struct {
+	adr  uint16
+	name string // subroutine the address belongs to
+}
+
+type profileSample struct {
+	frames []profileFrame // the innermost first
+	count  int64
+}
+
+func NewProfiler() *Profiler {
+	return &Profiler{samples: map[string]*profileSample{}, counts: map[uint16]int64{}, start: time.Now()}
+}
+
+// record counts the instruction at pc executed in the current call stack
+func (p *Profiler) record(chip *Chip8, pc uint16) {
+	p.total++
+	p.counts[pc]++
+
+	// the key is PC and the stack bytes as they are, big endian return addresses
+	p.key = append(p.key[:0], uint8(pc>>8), uint8(pc))
+	if top := int(chip.stackTop()); int(chip.Reg.SP) < top {
+		p.key = append(p.key, chip.Memory[int(chip.Reg.SP)+1:top+1]...)
+	}
+
+	if sample, ok := p.samples[string(p.key)]; ok {
+		sample.count++
+		return
+	}
+
+	// new path, the address belongs to the innermost subroutine, callers to the outer ones
+	sample := &profileSample{count: 1}
+	adr := pc
+	for _, frame := range chip.CallStack() {
+		sample.frames = append(sample.frames, profileFrame{adr: adr, name: p.name(frame.Entry)})
+		adr = frame.Caller
+	}
+	sample.frames = append(sample.frames, profileFrame{adr: adr, name: "main"})
+	p.samples[string(p.key)] = sample
+}
+
+// name returns subroutine name by its entry address
+func (p *Profiler) name(entry uint16) string {
+	if label, ok := p.Labels[entry]; ok {
+		return label
+	}
+	return fmt.Sprintf("sub_%04x", entry)
+}
+
+// Counts returns number of executions per instruction address
+func (p *Profiler) Counts() map[uint16]int64 {
+	counts := make(map[uint16]int64, len(p.counts))
+	for pc, n := range p.counts {
+		counts[pc] = n
+	}
+	return counts
+}
+
+// Total returns number of all executed instructions
+func (p *Profiler) Total() int64 {
+	return p.total
+}
+
+func (p *Profiler) nanoseconds(count int64) int64 {
+	ips := p.InstructionsPerSec
+	if ips <= 0 {
+		ips = DEFAULT_CYCLES_PER_FRAME * FRAME_RATE
+	}
+	return count * int64(time.Second) / int64(ips)
+}
+
+// Write writes gzipped pprof protobuf profile with instructions count and emulated time values
+func (p *Profiler) Write(w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	if _, err := gz.Write(p.encode()); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// encode builds perftools.profiles.Profile message (github.com/google/pprof/proto/profile.proto)
+func (p *Profiler) encode() []byte {
+	strs := []string{""}
+	strIndex := map[string]int64{"": 0}
+	str := func(s string) uint64 {
+		if i, ok := strIndex[s]; ok {
+			return uint64(i)
+		}
+		strIndex[s] = int64(len(strs))
+		strs = append(strs, s)
+		return uint64(len(strs) - 1)
+	}
+
+	var pb protoBuffer
+
+	valueType := func(tag int, typ, unit string) {
+		pb.message(tag, func(m *protoBuffer) {
+			m.uint64(1, str(typ))
+			m.uint64(2, str(unit))
+		})
+	}
+	valueType(1, "instructions", "count")
+	valueType(1, "cpu", "nanoseconds")
+
+	type locationKey struct {
+		adr  uint16
+		name string
+	}
+	locations := map[locationKey]uint64{}
+	functions := map[string]uint64{}
+	var locationOrder []locationKey
+	var functionOrder []string
+
+	paths := make([]string, 0, len(p.samples))
+	for path := range p.samples {
+		paths = append(paths, path)
+	}
+	// stable output for the same run
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		sample := p.samples[path]
+		ids := make([]uint64, 0, len(sample.frames))
+		for _, frame := range sample.frames {
+			key := locationKey{frame.adr, frame.name}
+			id, ok := locations[key]
+			if !ok {
+				id = uint64(len(locations) + 1)
+				locations[key] = id
+				locationOrder = append(locationOrder, key)
+			}
+			if _, ok := functions[frame.name]; !ok {
+				functions[frame.name] = uint64(len(functions) + 1)
+				functionOrder = append(functionOrder, frame.name)
+			}
+			ids = append(ids, id)
+		}
+
+		pb.message(2, func(m *protoBuffer) {
+			m.packed(1, ids)
+			m.packed(2, []uint64{uint64(sample.count), uint64(p.nanoseconds(sample.count))})
+		})
+	}
+
+	// the whole address space is a single mapping, so addresses are shown as they are
+	pb.message(3, func(m *protoBuffer) {
+		m.uint64(1, 1)
+		m.uint64(3, MEMORY_SIZE_XO)
+		m.uint64(5, str("rom"))
+		m.uint64(7, 1)
+	})
+
+	for _, key := range locationOrder {
+		pb.message(4, func(m *protoBuffer) {
+			m.uint64(1, locations[key])
+			m.uint64(2, 1)
+			m.uint64(3, uint64(key.adr))
+			m.message(4, func(line *protoBuffer) {
+				line.uint64(1, functions[key.name])
+			})
+		})
+	}
+
+	for _, name := range functionOrder {
+		pb.message(5, func(m *protoBuffer) {
+			m.uint64(1, functions[name])
+			m.uint64(2, str(name))
+			m.uint64(3, str(name))
+		})
+	}
+
+	periodType := str("cpu")
+	periodUnit := str("nanoseconds")
+
+	for _, s := range strs {
+		pb.bytes(6, []byte(s))
+	}
+
+	pb.uint64(9, uint64(p.start.UnixNano()))
+	pb.uint64(10, uint64(p.nanoseconds(p.total)))
+	pb.message(11, func(m *protoBuffer) {
+		m.uint64(1, periodType)
+		m.uint64(2, periodUnit)
+	})
+	pb.uint64(12, uint64(p.nanoseconds(1)))
+
+	return pb.data
+}
+
+// protoBuffer is a minimal protobuf encoder for varint and length delimited fields
+type protoBuffer struct {
+	data []byte
+}
+
+func (pb *protoBuffer) varint(v uint64) {
+	for v >= 0x80 {
+		pb.data = append(pb.data, uint8(v)|0x80)
+		v >>= 7
+	}
+	pb.data = append(pb.data, uint8(v))
+}
+
+// uint64 writes varint field, zero is the default value and is omitted
+func (pb *protoBuffer) uint64(tag int, v uint64) {
+	if v == 0 {
+		return
+	}
+	pb.varint(uint64(tag) << 3)
+	pb.varint(v)
+}
+
+func (pb *protoBuffer) bytes(tag int, b []byte) {
+	pb.varint(uint64(tag)<<3 | 2)
+	pb.varint(uint64(len(b)))
+	pb.data = append(pb.data, b...)
+}
+
+func (pb *protoBuffer) packed(tag int, vs []uint64) {
+	var packed protoBuffer
+	for _, v := range vs {
+		packed.varint(v)
+	}
+	pb.bytes(tag, packed.data)
+}
+
+func (pb *protoBuffer) message(tag int, encode func(m *protoBuffer)) {
+	var m protoBuffer
+	encode(&m)
+	pb.bytes(tag, m.data)
+}
@@ -0,0 +1,86 @@
+package chip8_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brus-fabrika/chip8/chip8"
+)
+
+// protoFields splits protobuf message into length delimited fields by tag, varint fields are skipped
+func protoFields(t *testing.T, data []byte) map[int][][]byte {
+	fields := map[int][][]byte{}
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		require.Positive(t, n)
+		data = data[n:]
+
+		switch key & 7 {
+		case 0:
+			_, n = binary.Uvarint(data)
+			require.Positive(t, n)
+			data = data[n:]
+		case 2:
+			size, n := binary.Uvarint(data)
+			require.Positive(t, n)
+			fields[int(key>>3)] = append(fields[int(key>>3)], data[n:n+int(size)])
+			data = data[n+int(size):]
+		default:
+			require.FailNow(t, "unexpected wire type", key&7)
+		}
+	}
+	return fields
+}
+
+func TestProfiler(t *testing.T) {
+	ch := chip8.Chip8{}
+	ch.Init(chip8.Chip_8)
+	profiler := chip8.NewProfiler()
+	profiler.Labels = map[uint16]string{0x0208: "add_two"}
+	ch.Profiler = profiler
+
+	// MOV V1, 00; CALL 0x0208; JMP 0x0202; ADD V1, 01; ADD V1, 01; RET
+	ch.LoadRomFromData([]uint8{0x61, 0x00, 0x22, 0x08, 0x12, 0x02, 0x00, 0x00, 0x71, 0x01, 0x71, 0x01, 0x00, 0xee})
+	for i := 0; i < 1+5*10; i++ {
+		assert.NoError(t, ch.Step())
+	}
+
+	assert.Equal(t, int64(51), profiler.Total())
+	assert.Equal(t, map[uint16]int64{0x0200: 1, 0x0202: 10, 0x0204: 10, 0x0208: 10, 0x020a: 10, 0x020c: 10}, profiler.Counts())
+
+	var buf bytes.Buffer
+	require.NoError(t, profiler.Write(&buf))
+	gz, err := gzip.NewReader(&buf)
+	require.NoError(t, err)
+	data, err := io.ReadAll(gz)
+	require.NoError(t, err)
+
+	fields := protoFields(t, data)
+	// sample types, samples (paths), locations, functions
+	assert.Len(t, fields[1], 2)
+	assert.Len(t, fields[2], 6)
+	assert.Len(t, fields[4], 6)
+	assert.Len(t, fields[5], 2)
+
+	var strs []string
+	for _, s := range fields[6] {
+		strs = append(strs, string(s))
+	}
+	assert.Equal(t, "", strs[0])
+	assert.Subset(t, strs, []string{"instructions", "count", "cpu", "nanoseconds", "main", "add_two"})
+}
+
+func TestProfilerDisabled(t *testing.T) {
+	ch := chip8.Chip8{}
+	ch.Init(chip8.Chip_8)
+	ch.LoadRomFromData([]uint8{0x12, 0x00})
+
+	allocs := testing.AllocsPerRun(100, func() { ch.Step() })
+	assert.Zero(t, allocs)
+}
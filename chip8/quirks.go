@@ -0,0 +1,177 @@
+package chip8
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Quirks holds behaviour differences between CHIP-8 platforms.
+// Init sets them from the ChipVersion preset, they can be overridden afterwards (i.e. per ROM).
+type Quirks struct {
+	ShiftVY       bool // 8XY6/8XYE: VX = VY before shift, otherwise VX is shifted in place
+	LoadStoreIncI bool // FX55/FX65: I = I + X + 1 after operation, otherwise I is not changed
+	JumpVX        bool // BNNN works as BXNN: jump to XNN + VX, otherwise NNN + V0
+	VFReset       bool // 8XY1/8XY2/8XY3: VF is reset to 0
+	ClipSprites   bool // DXYN: sprites are clipped at the screen edges, otherwise wrapped around
+	DisplayWait   bool // DXYN: drawing waits for the next display refresh (vblank)
+	AddIOverflow  bool // FX1E: VF = 1 if I overflows 0x0FFF, otherwise VF is not changed
+	CollisionRows bool // DXYN: in hires VF = number of collided (or clipped) rows, otherwise VF = 1 on collision
+	LegacyScroll  bool // 00CN/00FB/00FC: scroll by hires pixels in lores too, otherwise by current resolution pixels
+	ResClear      bool // 00FE/00FF: clear screen on resolution switch
+}
+
+var quirksPresets = map[ChipVersion]Quirks{
+	Chip_8: {
+		ShiftVY:       true,
+		LoadStoreIncI: true,
+		JumpVX:        false,
+		VFReset:       true,
+		ClipSprites:   true,
+		DisplayWait:   true,
+		AddIOverflow:  false,
+		CollisionRows: false,
+		LegacyScroll:  false,
+		ResClear:      false,
+	},
+	Super_Chip_Modern: {
+		ShiftVY:       false,
+		LoadStoreIncI: false,
+		JumpVX:        true,
+		VFReset:       false,
+		ClipSprites:   true,
+		DisplayWait:   false,
+		AddIOverflow:  false,
+		CollisionRows: false,
+		LegacyScroll:  false,
+		ResClear:      true,
+	},
+	Super_Chip_Legacy: {
+		ShiftVY:       false,
+		LoadStoreIncI: false,
+		JumpVX:        true,
+		VFReset:       false,
+		ClipSprites:   true,
+		DisplayWait:   false,
+		AddIOverflow:  false,
+		CollisionRows: true,
+		LegacyScroll:  true,
+		ResClear:      false,
+	},
+	XO_Chip: {
+		ShiftVY:       true,
+		LoadStoreIncI: true,
+		JumpVX:        false,
+		VFReset:       false,
+		ClipSprites:   false,
+		DisplayWait:   false,
+		AddIOverflow:  false,
+		CollisionRows: false,
+		LegacyScroll:  false,
+		ResClear:      true,
+	},
+}
+
+// DefaultQuirks returns quirks preset of the platform
+func DefaultQuirks(ver ChipVersion) Quirks {
+	return quirksPresets[ver]
+}
+
+// quirkNames maps short quirk names (used in override specs) to Quirks fields
+var quirkNames = []struct {
+	name  string
+	field func(q *Quirks) *bool
+}{
+	{"shift", func(q *Quirks) *bool { return &q.ShiftVY }},
+	{"loadstore", func(q *Quirks) *bool { return &q.LoadStoreIncI }},
+	{"jump", func(q *Quirks) *bool { return &q.JumpVX }},
+	{"vfreset", func(q *Quirks) *bool { return &q.VFReset }},
+	{"clip", func(q *Quirks) *bool { return &q.ClipSprites }},
+	{"vblank", func(q *Quirks) *bool { return &q.DisplayWait }},
+	{"addi", func(q *Quirks) *bool { return &q.AddIOverflow }},
+	{"rowcount", func(q *Quirks) *bool { return &q.CollisionRows }},
+	{"legacyscroll", func(q *Quirks) *bool { return &q.LegacyScroll }},
+	{"resclear", func(q *Quirks) *bool { return &q.ResClear }},
+}
+
+// Set switches single quirk by its short name (shift, loadstore, jump, vfreset, clip, vblank, addi, rowcount, legacyscroll, resclear)
+func (q *Quirks) Set(name string, on bool) error {
+	for _, qn := range quirkNames {
+		if qn.name == name {
+			*qn.field(q) = on
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown quirk %q", name)
+}
+
+// Override applies quirks spec on top of the current quirks.
+// Spec is a list of quirk names separated by commas or spaces, each one could be
+// in form "name" or "+name" (on), "-name" (off), "name=1"/"name=0" or "name=true"/"name=false".
+func (q *Quirks) Override(spec string) error {
+	items := strings.FieldsFunc(spec, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	})
+
+	for _, item := range items {
+		name, val, hasVal := strings.Cut(item, "=")
+		on := true
+
+		switch {
+		case hasVal:
+			switch strings.ToLower(val) {
+			case "1", "true", "on":
+				on = true
+			case "0", "false", "off":
+				on = false
+			default:
+				return fmt.Errorf("invalid quirk value %q", item)
+			}
+		case strings.HasPrefix(name, "-"):
+			name, on = name[1:], false
+		case strings.HasPrefix(name, "+"):
+			name = name[1:]
+		}
+
+		if err := q.Set(strings.ToLower(name), on); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LoadOverrideFile applies quirks spec stored in the file (i.e. "game.ch8.quirks" next to the ROM),
+// lines started with # are comments
+func (q *Quirks) LoadOverrideFile(fileName string) error {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+
+	var spec strings.Builder
+	for _, line := range strings.Split(string(data), "\n") {
+		if line, _, _ = strings.Cut(line, "#"); line != "" {
+			spec.WriteString(line)
+			spec.WriteString(" ")
+		}
+	}
+
+	return q.Override(spec.String())
+}
+
+// String returns quirks in the override spec form, i.e. "+shift,-jump,..."
+func (q Quirks) String() string {
+	items := make([]string, 0, len(quirkNames))
+
+	for _, qn := range quirkNames {
+		if *qn.field(&q) {
+			items = append(items, "+"+qn.name)
+		} else {
+			items = append(items, "-"+qn.name)
+		}
+	}
+
+	return strings.Join(items, ",")
+}
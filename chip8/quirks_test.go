@@ -0,0 +1,178 @@
+package chip8_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brus-fabrika/chip8/chip8"
+)
+
+func TestQuirksPresets(t *testing.T) {
+	ch := chip8.Chip8{}
+
+	for _, ver := range []chip8.ChipVersion{chip8.Chip_8, chip8.Super_Chip_Modern, chip8.Super_Chip_Legacy, chip8.XO_Chip} {
+		ch.Init(ver)
+		assert.Equal(t, chip8.DefaultQuirks(ver), ch.Quirks)
+	}
+
+	assert.True(t, chip8.DefaultQuirks(chip8.Chip_8).VFReset)
+	assert.True(t, chip8.DefaultQuirks(chip8.Chip_8).DisplayWait)
+	assert.False(t, chip8.DefaultQuirks(chip8.Super_Chip_Legacy).ShiftVY)
+	assert.True(t, chip8.DefaultQuirks(chip8.Super_Chip_Modern).JumpVX)
+	assert.False(t, chip8.DefaultQuirks(chip8.XO_Chip).ClipSprites)
+}
+
+func TestQuirkVFReset(t *testing.T) {
+	ch := chip8.Chip8{}
+	ch.Init(chip8.Chip_8)
+	ch.Quirks.VFReset = false
+
+	ch.Reg.V[0x0F] = 0x42
+	ch.Or(chip8.RegV1, chip8.RegV2)
+	ch.And(chip8.RegV1, chip8.RegV2)
+	ch.Xor(chip8.RegV1, chip8.RegV2)
+
+	assert.Equal(t, uint8(0x42), ch.Reg.V[0x0F])
+}
+
+func TestQuirkLoadStore(t *testing.T) {
+	ch := chip8.Chip8{}
+
+	t.Run("IncI", func(t *testing.T) {
+		ch.Init(chip8.Chip_8)
+		ch.Reg.I = 0x0300
+		ch.Reg.V = [16]uint8{1, 2, 3, 4}
+
+		ch.CopyRegToMem(chip8.RegV3)
+		assert.Equal(t, uint16(0x0304), ch.Reg.I)
+		assert.Equal(t, []uint8{1, 2, 3, 4}, ch.Memory[0x0300:0x0304])
+
+		ch.Reg.I = 0x0300
+		ch.Reg.V = [16]uint8{}
+		ch.CopyMemToReg(chip8.RegV2)
+		assert.Equal(t, uint16(0x0303), ch.Reg.I)
+		assert.Equal(t, [16]uint8{1, 2, 3}, ch.Reg.V)
+	})
+
+	t.Run("KeepI", func(t *testing.T) {
+		ch.Init(chip8.Super_Chip_Modern)
+		ch.Reg.I = 0x0300
+		ch.Reg.V = [16]uint8{1, 2, 3, 4}
+
+		ch.CopyRegToMem(chip8.RegV3)
+		assert.Equal(t, uint16(0x0300), ch.Reg.I)
+		assert.Equal(t, []uint8{1, 2, 3, 4}, ch.Memory[0x0300:0x0304])
+
+		ch.Reg.V = [16]uint8{}
+		ch.CopyMemToReg(chip8.RegV3)
+		assert.Equal(t, uint16(0x0300), ch.Reg.I)
+		assert.Equal(t, [16]uint8{1, 2, 3, 4}, ch.Reg.V)
+	})
+}
+
+func TestQuirkJump(t *testing.T) {
+	ch := chip8.Chip8{}
+	ch.Init(chip8.Super_Chip_Modern)
+	ch.Reg.V[0] = 0x10
+	ch.Reg.V[3] = 0x20
+
+	ch.JumpV(0x0345)
+	assert.Equal(t, uint16(0x0365), ch.Reg.PC)
+
+	ch.Quirks.JumpVX = false
+	ch.JumpV(0x0345)
+	assert.Equal(t, uint16(0x0355), ch.Reg.PC)
+}
+
+func TestQuirkClipSprites(t *testing.T) {
+	ch := chip8.Chip8{}
+
+	// 8x2 sprite drawn at the bottom right corner
+	setup := func(ver chip8.ChipVersion) {
+		ch.Init(ver)
+		ch.Memory[0x0300] = 0xff
+		ch.Memory[0x0301] = 0xff
+		ch.Reg.I = 0x0300
+		ch.Reg.V[1] = chip8.DISPLAY_LORES_WIDTH - 4
+		ch.Reg.V[2] = chip8.DISPLAY_LORES_HEIGHT - 1
+	}
+
+	t.Run("Clip", func(t *testing.T) {
+		setup(chip8.Chip_8)
+		ch.DisplayAt(chip8.RegV1, chip8.RegV2, 2)
+
+		assert.True(t, ch.Pixel(chip8.DISPLAY_LORES_WIDTH-1, chip8.DISPLAY_LORES_HEIGHT-1))
+		assert.False(t, ch.Pixel(0, 0))
+		assert.False(t, ch.Pixel(chip8.DISPLAY_LORES_WIDTH-1, 0))
+	})
+
+	t.Run("Wrap", func(t *testing.T) {
+		setup(chip8.XO_Chip)
+		ch.DisplayAt(chip8.RegV1, chip8.RegV2, 2)
+
+		assert.True(t, ch.Pixel(chip8.DISPLAY_LORES_WIDTH-1, chip8.DISPLAY_LORES_HEIGHT-1))
+		// wrapped horizontally, then vertically
+		assert.True(t, ch.Pixel(0, chip8.DISPLAY_LORES_HEIGHT-1))
+		assert.True(t, ch.Pixel(0, 0))
+		assert.True(t, ch.Pixel(chip8.DISPLAY_LORES_WIDTH-1, 0))
+	})
+}
+
+func TestQuirkAddIOverflow(t *testing.T) {
+	ch := chip8.Chip8{}
+	ch.Init(chip8.Chip_8)
+	ch.Reg.I = 0x0ffe
+	ch.Reg.V[1] = 0x04
+	ch.Reg.V[0x0F] = 0x42
+
+	ch.AddIReg(chip8.RegV1)
+	assert.Equal(t, uint16(0x1002), ch.Reg.I)
+	assert.Equal(t, uint8(0x42), ch.Reg.V[0x0F])
+
+	ch.Quirks.AddIOverflow = true
+	ch.Reg.I = 0x0ffe
+	ch.AddIReg(chip8.RegV1)
+	assert.Equal(t, uint16(0x1002), ch.Reg.I)
+	assert.Equal(t, uint8(0x01), ch.Reg.V[0x0F])
+
+	ch.Reg.I = 0x0100
+	ch.AddIReg(chip8.RegV1)
+	assert.Equal(t, uint8(0x00), ch.Reg.V[0x0F])
+}
+
+func TestQuirksOverride(t *testing.T) {
+	q := chip8.DefaultQuirks(chip8.Chip_8)
+
+	if assert.NoError(t, q.Override("-shift, jump=1 clip=false,+addi")) {
+		assert.False(t, q.ShiftVY)
+		assert.True(t, q.JumpVX)
+		assert.False(t, q.ClipSprites)
+		assert.True(t, q.AddIOverflow)
+		assert.True(t, q.VFReset) // not touched
+	}
+
+	assert.Error(t, q.Override("unknown"))
+	assert.Error(t, q.Override("clip=maybe"))
+
+	// String output could be used as an override spec
+	q2 := chip8.Quirks{}
+	if assert.NoError(t, q2.Override(q.String())) {
+		assert.Equal(t, q, q2)
+	}
+}
+
+func TestQuirksLoadOverrideFile(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "game.ch8.quirks")
+	os.WriteFile(fileName, []uint8("# needs modern shifting\n-shift\r\nvblank=0\n"), 0o644)
+
+	q := chip8.DefaultQuirks(chip8.Chip_8)
+	if assert.NoError(t, q.LoadOverrideFile(fileName)) {
+		assert.False(t, q.ShiftVY)
+		assert.False(t, q.DisplayWait)
+	}
+
+	assert.Error(t, q.LoadOverrideFile(fileName+".missing"))
+}
@@ -0,0 +1,141 @@
+package chip8
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	FRAME_RATE               = 60 // timers and display refresh rate, Hz
+	DEFAULT_CYCLES_PER_FRAME = 8  // ~500 instructions per second
+)
+
+// Clock paces the run loop, Wait blocks until the next frame tick or the context is done
+type Clock interface {
+	Wait(ctx context.Context) error
+}
+
+// RealtimeClock ticks FRAME_RATE times per second
+type RealtimeClock struct {
+	ticker *time.Ticker
+}
+
+func NewRealtimeClock() *RealtimeClock {
+	return &RealtimeClock{ticker: time.NewTicker(time.Second / FRAME_RATE)}
+}
+
+func (c *RealtimeClock) Wait(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.ticker.C:
+		return nil
+	}
+}
+
+func (c *RealtimeClock) Stop() {
+	c.ticker.Stop()
+}
+
+// FreeClock never waits, frames are run as fast as possible (headless runs, benchmarks, tests)
+type FreeClock struct{}
+
+func (FreeClock) Wait(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// UpdateTimer decrements delay (T0) and sound (T1) timers, called once per frame
+func (chip *Chip8) UpdateTimer() {
+	if chip.Reg.T0 > 0 {
+		chip.Reg.T0--
+	}
+
+	if chip.Reg.T1 > 0 {
+		chip.Reg.T1--
+	}
+}
+
+// RunFrame executes one 60 Hz frame: up to cycles instructions and the timers tick.
+// Frame ends earlier on draw with display wait quirk (vblank).
+// Nothing is done while the machine is paused or stopped. Returns the last execution error of the frame.
+func (chip *Chip8) RunFrame(cycles int) error {
+	if !chip.State.Running || chip.State.Paused {
+		return nil
+	}
+
+	// new frame, vblank has come
+	chip.frameSteps = 0
+	chip.State.WaitingForVBlank = false
+
+	var err error
+	for cycles > 0 && chip.State.Running && !chip.State.Paused {
+		end, stepErr := chip.frameStep(cycles)
+		if stepErr != nil {
+			err = stepErr
+		}
+		if end {
+			return err
+		}
+	}
+
+	// stopped or paused in the middle, the frame is over anyway
+	chip.endFrame()
+	return err
+}
+
+// FrameStep executes one instruction of the current frame, so debuggers stepping instructions one at a time
+// keep RunFrame timing: the frame ends after CyclesPerFrame instructions or on vblank wait, then the timers tick.
+// Returns true if the instruction has ended the frame, and the execution error.
+func (chip *Chip8) FrameStep() (bool, error) {
+	return chip.frameStep(chip.cyclesPerFrame())
+}
+
+func (chip *Chip8) frameStep(cycles int) (bool, error) {
+	if chip.frameSteps == 0 {
+		// new frame, vblank has come
+		chip.State.WaitingForVBlank = false
+	}
+
+	err := chip.Step()
+	chip.frameSteps++
+
+	if chip.frameSteps < cycles && !chip.State.WaitingForVBlank {
+		return false, err
+	}
+	chip.endFrame()
+	return true, err
+}
+
+func (chip *Chip8) endFrame() {
+	chip.frameSteps = 0
+	chip.UpdateTimer()
+}
+
+// cyclesPerFrame returns CyclesPerFrame or the default if it is not set
+func (chip *Chip8) cyclesPerFrame() int {
+	if chip.CyclesPerFrame <= 0 {
+		return DEFAULT_CYCLES_PER_FRAME
+	}
+	return chip.CyclesPerFrame
+}
+
+// Run executes frames paced by the clock until the machine stops or the context is cancelled.
+// OnFrame is called after every frame, paused ones included, so frontends could handle input and redraw.
+// Returns context error on cancel, otherwise the last execution error (nil for a normal exit).
+func (chip *Chip8) Run(ctx context.Context, clock Clock) error {
+	cycles := chip.cyclesPerFrame()
+
+	for chip.State.Running {
+		if err := clock.Wait(ctx); err != nil {
+			return err
+		}
+
+		chip.RunFrame(cycles)
+
+		if chip.OnFrame != nil {
+			chip.OnFrame()
+		}
+	}
+
+	return chip.State.Err
+}
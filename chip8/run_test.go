@@ -0,0 +1,166 @@
+package chip8_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brus-fabrika/chip8/chip8"
+)
+
+func TestRunFrame(t *testing.T) {
+	ch := chip8.Chip8{}
+	ch.Init(chip8.Chip_8)
+
+	// ADD V1, 01; JMP 0x0200
+	ch.LoadRomFromData([]uint8{0x71, 0x01, 0x12, 0x00})
+	ch.Reg.T0 = 2
+	ch.Reg.T1 = 1
+
+	assert.NoError(t, ch.RunFrame(8))
+	assert.Equal(t, uint8(4), ch.Reg.V[1])
+	assert.Equal(t, uint8(1), ch.Reg.T0)
+	assert.Equal(t, uint8(0), ch.Reg.T1)
+	assert.Equal(t, uint64(8), ch.Cycles)
+
+	// paused machine does nothing, timers included
+	ch.State.Paused = true
+	assert.NoError(t, ch.RunFrame(8))
+	assert.Equal(t, uint8(4), ch.Reg.V[1])
+	assert.Equal(t, uint8(1), ch.Reg.T0)
+	assert.Equal(t, uint64(8), ch.Cycles)
+}
+
+func TestFrameStep(t *testing.T) {
+	// ADD V1, 01; DRAW 0, V1, 1; ADD V2, 01; JMP 0x0200
+	rom := []uint8{0x71, 0x01, 0xd0, 0x11, 0x72, 0x01, 0x12, 0x00}
+
+	framed, stepped := chip8.Chip8{}, chip8.Chip8{}
+	for _, ch := range []*chip8.Chip8{&framed, &stepped} {
+		ch.Init(chip8.Chip_8)
+		ch.LoadRomFromData(rom)
+		ch.CyclesPerFrame = 3
+		ch.Reg.T0 = 0xff
+	}
+
+	// frame ends on DRAW with vblank wait, the next one after 3 instructions
+	end, err := stepped.FrameStep()
+	assert.NoError(t, err)
+	assert.False(t, end)
+	end, _ = stepped.FrameStep()
+	assert.True(t, end)
+	assert.True(t, stepped.State.WaitingForVBlank)
+	assert.Equal(t, uint8(0xfe), stepped.Reg.T0)
+
+	for _, want := range []bool{false, false, true} {
+		end, _ = stepped.FrameStep()
+		assert.Equal(t, want, end)
+	}
+	assert.Equal(t, uint8(0xfd), stepped.Reg.T0)
+
+	// stepping keeps the same timing as running frames
+	for frame := 0; frame < 2; frame++ {
+		framed.RunFrame(framed.CyclesPerFrame)
+	}
+	assert.Equal(t, framed.Reg, stepped.Reg)
+
+	for frame := 0; frame < 10; frame++ {
+		framed.RunFrame(framed.CyclesPerFrame)
+		for end := false; !end; {
+			end, _ = stepped.FrameStep()
+		}
+		assert.Equal(t, framed.Reg, stepped.Reg, "frame %d", frame)
+	}
+}
+
+func TestRunFrameError(t *testing.T) {
+	ch := chip8.Chip8{}
+	ch.Init(chip8.Chip_8)
+	ch.OnError = chip8.PolicyTrap
+
+	// ADD V1, 01; invalid
+	ch.LoadRomFromData([]uint8{0x71, 0x01, 0xff, 0xff})
+
+	assert.ErrorIs(t, ch.RunFrame(8), chip8.ErrInvalidOpcode)
+	assert.True(t, ch.State.Paused)
+	assert.Equal(t, uint8(1), ch.Reg.V[1])
+	assert.Equal(t, uint16(0x0202), ch.Reg.PC)
+	// failed instruction is not counted
+	assert.Equal(t, uint64(1), ch.Cycles)
+}
+
+func TestRun(t *testing.T) {
+	ch := chip8.Chip8{}
+
+	t.Run("Exit", func(t *testing.T) {
+		ch.Init(chip8.Super_Chip_Modern)
+		// ADD V1, 01; SE V1, 14; JMP 0x0200; EXIT
+		ch.LoadRomFromData([]uint8{0x71, 0x01, 0x31, 0x14, 0x12, 0x00, 0x00, 0xfd})
+		ch.CyclesPerFrame = 3
+
+		frames := 0
+		ch.OnFrame = func() { frames++ }
+
+		assert.NoError(t, ch.Run(context.Background(), chip8.FreeClock{}))
+		assert.False(t, ch.State.Running)
+		assert.Equal(t, uint8(0x14), ch.Reg.V[1])
+		assert.Equal(t, 20, frames)
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		ch.Init(chip8.Chip_8)
+		ch.LoadRomFromData([]uint8{0x12, 0x00}) // JMP 0x0200
+		ch.CyclesPerFrame = 0
+
+		ctx, cancel := context.WithCancel(context.Background())
+		frames := 0
+		ch.OnFrame = func() {
+			frames++
+			if frames == 5 {
+				cancel()
+			}
+		}
+
+		assert.ErrorIs(t, ch.Run(ctx, chip8.FreeClock{}), context.Canceled)
+		assert.True(t, ch.State.Running)
+		assert.Equal(t, 5, frames)
+	})
+
+	t.Run("Halt", func(t *testing.T) {
+		ch.Init(chip8.Chip_8)
+		ch.LoadRomFromData([]uint8{0xff, 0xff})
+		ch.OnFrame = nil
+
+		assert.ErrorIs(t, ch.Run(context.Background(), chip8.FreeClock{}), chip8.ErrInvalidOpcode)
+	})
+}
+
+func TestRunFrameDisplayWait(t *testing.T) {
+	ch := chip8.Chip8{}
+
+	// DRAW 1, V0, V0; ADD V1, 01; JMP 0x0200
+	rom := []uint8{0xd0, 0x01, 0x71, 0x01, 0x12, 0x00}
+
+	ch.Init(chip8.Chip_8)
+	ch.LoadRomFromData(rom)
+	ch.Reg.T0 = 2
+
+	// draw yields the rest of the frame
+	assert.NoError(t, ch.RunFrame(8))
+	assert.True(t, ch.State.WaitingForVBlank)
+	assert.Equal(t, uint16(0x0202), ch.Reg.PC)
+	assert.Equal(t, uint8(1), ch.Reg.T0)
+
+	assert.NoError(t, ch.RunFrame(8))
+	assert.Equal(t, uint8(1), ch.Reg.V[1])
+	assert.Equal(t, uint16(0x0202), ch.Reg.PC)
+
+	// no wait on SCHIP
+	ch.Init(chip8.Super_Chip_Modern)
+	ch.LoadRomFromData(rom)
+
+	assert.NoError(t, ch.RunFrame(8))
+	assert.False(t, ch.State.WaitingForVBlank)
+	assert.Equal(t, uint8(3), ch.Reg.V[1])
+}
@@ -0,0 +1,168 @@
+package chip8
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+)
+
+// SCHIP 1.1 big hex font 8x10, (A-F taken from Octo)
+var big_font_data = []uint8{
+	0xFF, 0xFF, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xFF, 0xFF, // 0
+	0x18, 0x78, 0x78, 0x18, 0x18, 0x18, 0x18, 0x18, 0xFF, 0xFF, // 1
+	0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, // 2
+	0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, // 3
+	0xC3, 0xC3, 0xC3, 0xC3, 0xFF, 0xFF, 0x03, 0x03, 0x03, 0x03, // 4
+	0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, // 5
+	0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF, // 6
+	0xFF, 0xFF, 0x03, 0x03, 0x06, 0x0C, 0x18, 0x18, 0x18, 0x18, // 7
+	0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF, // 8
+	0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, // 9
+	0x7E, 0xFF, 0xC3, 0xC3, 0xC3, 0xFF, 0xFF, 0xC3, 0xC3, 0xC3, // A
+	0xFC, 0xFC, 0xC3, 0xC3, 0xFC, 0xFC, 0xC3, 0xC3, 0xFC, 0xFC, // B
+	0x3C, 0xFF, 0xC3, 0xC0, 0xC0, 0xC0, 0xC0, 0xC3, 0xFF, 0x3C, // C
+	0xFC, 0xFE, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xFE, 0xFC, // D
+	0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, // E
+	0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0xC0, 0xC0, 0xC0, 0xC0, // F
+}
+
+// schipPlatforms are the platforms supporting SCHIP 1.1 instructions
+var schipPlatforms = []ChipVersion{Super_Chip_Modern, Super_Chip_Legacy, XO_Chip}
+
+// FlagsStore keeps SCHIP RPL user flags between the runs
+type FlagsStore interface {
+	LoadFlags() ([16]uint8, error)
+	SaveFlags(flags [16]uint8) error
+}
+
+// FlagsFile is a FlagsStore keeping flags in a file, i.e. next to the ROM as "game.ch8.flags"
+type FlagsFile string
+
+func (f FlagsFile) LoadFlags() ([16]uint8, error) {
+	var flags [16]uint8
+
+	data, err := os.ReadFile(string(f))
+	if errors.Is(err, fs.ErrNotExist) {
+		// nothing saved yet
+		return flags, nil
+	}
+	if err != nil {
+		return flags, err
+	}
+
+	copy(flags[:], data)
+	return flags, nil
+}
+
+func (f FlagsFile) SaveFlags(flags [16]uint8) error {
+	return os.WriteFile(string(f), flags[:], 0o644)
+}
+
+// SetFlagsStore sets persistent storage for RPL flags and loads flags from it
+func (chip *Chip8) SetFlagsStore(store FlagsStore) error {
+	chip.Flags = store
+	if store == nil {
+		return nil
+	}
+
+	flags, err := store.LoadFlags()
+	if err != nil {
+		return err
+	}
+	chip.RPL = flags
+
+	return nil
+}
+
+func (chip *Chip8) SaveFlagsReg(r Register) {
+	for x := 0; x <= int(r); x++ {
+		chip.RPL[x] = chip.Reg.V[x]
+	}
+
+	if chip.Flags != nil {
+		// storage errors are not fatal for the program, flags are still kept in RPL
+		chip.Flags.SaveFlags(chip.RPL)
+	}
+
+	chip.Reg.PC += 2
+}
+
+func (chip *Chip8) LoadFlagsReg(r Register) {
+	for x := 0; x <= int(r); x++ {
+		chip.Reg.V[x] = chip.RPL[x]
+	}
+	chip.Reg.PC += 2
+}
+
+func (chip *Chip8) SetBigCharReg(r Register) {
+	val := chip.getRegister(r) & 0x000F
+	chip.Reg.I = MEMORY_BIG_FONT + val*10
+
+	chip.Reg.PC += 2
+}
+
+func (chip *Chip8) SetHires(on bool) {
+	if chip.Quirks.ResClear {
+		for i := range chip.DisplayBuffer {
+			chip.DisplayBuffer[i] = 0
+		}
+	}
+	chip.Hires = on
+
+	chip.Reg.PC += 2
+}
+
+// Exit stops the machine, PC stays at the exit instruction
+func (chip *Chip8) Exit() {
+	chip.State.Running = false
+}
+
+// scrollStep returns the number of DisplayBuffer pixels in one scroll pixel
+func (chip *Chip8) scrollStep() int {
+	if chip.Quirks.LegacyScroll {
+		// SCHIP 1.1 scrolls by hires pixels, so only a half of lores pixel in lores mode
+		return 1
+	}
+	return chip.displayScale()
+}
+
+func (chip *Chip8) ScrollDown(n int) {
+	chip.scroll(0, n*chip.scrollStep())
+	chip.Reg.PC += 2
+}
+
+// ScrollUp is XO-CHIP 00DN
+func (chip *Chip8) ScrollUp(n int) {
+	chip.scroll(0, -n*chip.scrollStep())
+	chip.Reg.PC += 2
+}
+
+func (chip *Chip8) ScrollRight() {
+	chip.scroll(4*chip.scrollStep(), 0)
+	chip.Reg.PC += 2
+}
+
+func (chip *Chip8) ScrollLeft() {
+	chip.scroll(-4*chip.scrollStep(), 0)
+	chip.Reg.PC += 2
+}
+
+// scroll moves selected planes of the display buffer by dx, dy pixels, pixels scrolled out of the screen are lost
+func (chip *Chip8) scroll(dx, dy int) {
+	var buf [DISPLAY_WIDTH * DISPLAY_HEIGHT]uint8
+
+	for y := 0; y < DISPLAY_HEIGHT; y++ {
+		for x := 0; x < DISPLAY_WIDTH; x++ {
+			i := x + y*DISPLAY_WIDTH
+			// not selected planes stay in place
+			buf[i] = chip.DisplayBuffer[i] &^ chip.Planes
+
+			sx, sy := x-dx, y-dy
+			if sx >= 0 && sx < DISPLAY_WIDTH && sy >= 0 && sy < DISPLAY_HEIGHT {
+				buf[i] |= chip.DisplayBuffer[sx+sy*DISPLAY_WIDTH] & chip.Planes
+			}
+		}
+	}
+
+	chip.DisplayBuffer = buf
+}
@@ -0,0 +1,222 @@
+package chip8_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brus-fabrika/chip8/chip8"
+)
+
+func TestSchipOpcodesPlatform(t *testing.T) {
+	ch := chip8.Chip8{}
+
+	for _, cmd := range []uint16{0x00c1, 0x00fb, 0x00fc, 0x00fd, 0x00fe, 0x00ff, 0xf130, 0xf175, 0xf185} {
+		ch.Init(chip8.Chip_8)
+		assert.ErrorIs(t, ch.ProcessCmd(cmd), chip8.ErrInvalidOpcode, "%04x", cmd)
+
+		ch.Init(chip8.Super_Chip_Modern)
+		assert.NoError(t, ch.ProcessCmd(cmd), "%04x", cmd)
+	}
+}
+
+func TestSetHires(t *testing.T) {
+	ch := chip8.Chip8{}
+
+	t.Run("Modern", func(t *testing.T) {
+		ch.Init(chip8.Super_Chip_Modern)
+		ch.DisplayBuffer[10] = 1
+
+		ch.ProcessCmd(0x00ff) // HIGH
+		w, h := ch.DisplaySize()
+		assert.True(t, ch.Hires)
+		assert.Equal(t, chip8.DISPLAY_WIDTH, w)
+		assert.Equal(t, chip8.DISPLAY_HEIGHT, h)
+		assert.Zero(t, ch.DisplayBuffer[10]) // resolution switch clears the screen
+
+		ch.ProcessCmd(0x00fe) // LOW
+		w, h = ch.DisplaySize()
+		assert.False(t, ch.Hires)
+		assert.Equal(t, chip8.DISPLAY_LORES_WIDTH, w)
+		assert.Equal(t, chip8.DISPLAY_LORES_HEIGHT, h)
+		assert.Equal(t, uint16(0x0204), ch.Reg.PC)
+	})
+
+	t.Run("Legacy", func(t *testing.T) {
+		ch.Init(chip8.Super_Chip_Legacy)
+		ch.DisplayBuffer[10] = 1
+
+		ch.ProcessCmd(0x00ff) // HIGH
+		assert.True(t, ch.Hires)
+		assert.Equal(t, uint8(1), ch.DisplayBuffer[10])
+	})
+}
+
+func TestDisplayAtLores(t *testing.T) {
+	ch := chip8.Chip8{}
+	ch.Init(chip8.Chip_8)
+	ch.Memory[0x0300] = 0x80
+	ch.Reg.I = 0x0300
+	ch.Reg.V[1] = 1
+	ch.Reg.V[2] = 2
+
+	ch.DisplayAt(chip8.RegV1, chip8.RegV2, 1)
+
+	// lores pixel is 2x2 block in display buffer
+	assert.True(t, ch.Pixel(1, 2))
+	assert.Equal(t, uint8(1), ch.DisplayBuffer[2+4*chip8.DISPLAY_WIDTH])
+	assert.Equal(t, uint8(1), ch.DisplayBuffer[3+4*chip8.DISPLAY_WIDTH])
+	assert.Equal(t, uint8(1), ch.DisplayBuffer[2+5*chip8.DISPLAY_WIDTH])
+	assert.Equal(t, uint8(1), ch.DisplayBuffer[3+5*chip8.DISPLAY_WIDTH])
+	assert.Equal(t, uint8(0), ch.Reg.V[0x0F])
+
+	ch.DisplayAt(chip8.RegV1, chip8.RegV2, 1)
+	assert.Equal(t, [chip8.DISPLAY_WIDTH * chip8.DISPLAY_HEIGHT]uint8{}, ch.DisplayBuffer)
+	assert.Equal(t, uint8(1), ch.Reg.V[0x0F])
+}
+
+func TestDisplayAtBigSprite(t *testing.T) {
+	ch := chip8.Chip8{}
+
+	setup := func(ver chip8.ChipVersion) {
+		ch.Init(ver)
+		ch.Hires = true
+		for i := 0; i < 32; i++ {
+			ch.Memory[0x0300+i] = 0xff
+		}
+		ch.Reg.I = 0x0300
+		ch.Reg.V[1] = 0
+		ch.Reg.V[2] = 0
+	}
+
+	t.Run("16x16", func(t *testing.T) {
+		setup(chip8.Super_Chip_Modern)
+		ch.ProcessCmd(0xd120)
+
+		assert.True(t, ch.Pixel(15, 15))
+		assert.False(t, ch.Pixel(16, 15))
+		assert.False(t, ch.Pixel(15, 16))
+	})
+
+	t.Run("Chip8NoBigSprite", func(t *testing.T) {
+		setup(chip8.Chip_8)
+		ch.ProcessCmd(0xd120)
+
+		assert.Equal(t, [chip8.DISPLAY_WIDTH * chip8.DISPLAY_HEIGHT]uint8{}, ch.DisplayBuffer)
+	})
+
+	t.Run("CollisionModern", func(t *testing.T) {
+		setup(chip8.Super_Chip_Modern)
+		ch.ProcessCmd(0xd120)
+		ch.Reg.V[2] = 8
+		ch.ProcessCmd(0xd120)
+
+		assert.Equal(t, uint8(1), ch.Reg.V[0x0F])
+	})
+
+	t.Run("CollisionLegacyRows", func(t *testing.T) {
+		setup(chip8.Super_Chip_Legacy)
+		ch.ProcessCmd(0xd120)
+		ch.Reg.V[2] = 8
+		ch.ProcessCmd(0xd120)
+
+		// 8 rows overlapped
+		assert.Equal(t, uint8(8), ch.Reg.V[0x0F])
+
+		// 4 rows clipped at the bottom and no collision
+		ch.ClearScreen()
+		ch.Reg.V[2] = chip8.DISPLAY_HEIGHT - 12
+		ch.ProcessCmd(0xd120)
+		assert.Equal(t, uint8(4), ch.Reg.V[0x0F])
+	})
+}
+
+func TestScroll(t *testing.T) {
+	ch := chip8.Chip8{}
+
+	setup := func(ver chip8.ChipVersion, hires bool) {
+		ch.Init(ver)
+		ch.Hires = hires
+		ch.DisplayBuffer[8+8*chip8.DISPLAY_WIDTH] = 1
+	}
+
+	t.Run("DownHires", func(t *testing.T) {
+		setup(chip8.Super_Chip_Modern, true)
+		ch.ProcessCmd(0x00c3)
+		assert.Equal(t, uint8(1), ch.DisplayBuffer[8+11*chip8.DISPLAY_WIDTH])
+		assert.Zero(t, ch.DisplayBuffer[8+8*chip8.DISPLAY_WIDTH])
+	})
+
+	t.Run("DownLoresModern", func(t *testing.T) {
+		setup(chip8.Super_Chip_Modern, false)
+		ch.ProcessCmd(0x00c3)
+		assert.Equal(t, uint8(1), ch.DisplayBuffer[8+14*chip8.DISPLAY_WIDTH])
+	})
+
+	t.Run("DownLoresLegacy", func(t *testing.T) {
+		setup(chip8.Super_Chip_Legacy, false)
+		ch.ProcessCmd(0x00c3)
+		assert.Equal(t, uint8(1), ch.DisplayBuffer[8+11*chip8.DISPLAY_WIDTH])
+	})
+
+	t.Run("Right", func(t *testing.T) {
+		setup(chip8.Super_Chip_Modern, true)
+		ch.ProcessCmd(0x00fb)
+		assert.Equal(t, uint8(1), ch.DisplayBuffer[12+8*chip8.DISPLAY_WIDTH])
+		assert.Zero(t, ch.DisplayBuffer[8+8*chip8.DISPLAY_WIDTH])
+	})
+
+	t.Run("Left", func(t *testing.T) {
+		setup(chip8.Super_Chip_Modern, false)
+		ch.ProcessCmd(0x00fc)
+		assert.Equal(t, uint8(1), ch.DisplayBuffer[0+8*chip8.DISPLAY_WIDTH])
+		assert.Zero(t, ch.DisplayBuffer[8+8*chip8.DISPLAY_WIDTH])
+
+		// pixel scrolled out of the screen is lost
+		ch.ProcessCmd(0x00fc)
+		assert.Equal(t, [chip8.DISPLAY_WIDTH * chip8.DISPLAY_HEIGHT]uint8{}, ch.DisplayBuffer)
+	})
+}
+
+func TestSetBigCharReg(t *testing.T) {
+	ch := chip8.Chip8{}
+	ch.Init(chip8.Super_Chip_Modern)
+	ch.Reg.V[3] = 0x17
+
+	ch.SetBigCharReg(chip8.RegV3)
+
+	assert.Equal(t, chip8.MEMORY_BIG_FONT+70, ch.Reg.I)
+	assert.Equal(t, uint8(0xFF), ch.Memory[ch.Reg.I]) // "7" starts with full line
+	assert.Equal(t, uint16(0x0202), ch.Reg.PC)
+}
+
+func TestFlags(t *testing.T) {
+	store := chip8.FlagsFile(filepath.Join(t.TempDir(), "game.ch8.flags"))
+
+	ch := chip8.Chip8{}
+	ch.Init(chip8.Super_Chip_Modern)
+	assert.NoError(t, ch.SetFlagsStore(store))
+
+	ch.Reg.V = [16]uint8{1, 2, 3, 4, 5}
+	ch.ProcessCmd(0xf375) // SAVEF V3
+	assert.Equal(t, [16]uint8{1, 2, 3, 4}, ch.RPL)
+
+	// next run of the same ROM gets the flags back
+	ch2 := chip8.Chip8{}
+	ch2.Init(chip8.Super_Chip_Modern)
+	assert.NoError(t, ch2.SetFlagsStore(store))
+
+	ch2.ProcessCmd(0xf785) // LOADF V7
+	assert.Equal(t, [16]uint8{1, 2, 3, 4}, ch2.Reg.V)
+}
+
+func TestExit(t *testing.T) {
+	ch := chip8.Chip8{}
+	ch.Init(chip8.Super_Chip_Legacy)
+	ch.LoadRomFromData([]uint8{0x00, 0xfd})
+
+	assert.NoError(t, ch.Step())
+	assert.False(t, ch.State.Running)
+	assert.Equal(t, uint16(0x0200), ch.Reg.PC)
+}
@@ -0,0 +1,178 @@
+package chip8
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+)
+
+// Snapshot format: header, state payload (little endian) and CRC32 of both.
+// SNAPSHOT_VERSION must be increased on any change of snapshotState layout.
+const (
+	SNAPSHOT_MAGIC   = "C8ST"
+	SNAPSHOT_VERSION = 1
+)
+
+var (
+	ErrSnapshotFormat   = errors.New("not a snapshot")
+	ErrSnapshotVersion  = errors.New("incompatible snapshot version")
+	ErrSnapshotChecksum = errors.New("snapshot checksum mismatch")
+)
+
+type snapshotHeader struct {
+	Magic   [4]uint8
+	Version uint16
+	Size    uint32 // payload size
+}
+
+// snapshotState is the machine state as it is stored, fixed size fields only.
+// Memory and display are written as they are, so the snapshot is appended without temporary copies of them.
+type snapshotState struct {
+	Head          snapshotHead
+	Memory        [MEMORY_SIZE_XO]uint8
+	DisplayBuffer [DISPLAY_WIDTH * DISPLAY_HEIGHT]uint8
+	Tail          snapshotTail
+}
+
+type snapshotHead struct {
+	Ver    int32
+	Quirks Quirks
+}
+
+type snapshotTail struct {
+	Hires        bool
+	Planes       uint8
+	AudioPattern [16]uint8
+	AudioPitch   uint8
+	Keyboard     [0x10]bool
+	KeyLatch     int8
+	Reg          RegisterSet
+	RomSize      uint16
+	Rng          uint32
+	RPL          [16]uint8
+
+	Running          bool
+	Paused           bool
+	WaitingForVBlank bool
+	WaitingForKey    bool
+}
+
+var snapshotSize = binary.Size(&snapshotState{})
+
+// Snapshot serializes the machine state (memory, display, registers, timers, quirks, RNG),
+// handlers and storages are not part of it
+func (chip *Chip8) Snapshot() ([]byte, error) {
+	return chip.AppendSnapshot(nil)
+}
+
+// AppendSnapshot appends the snapshot to dst and returns the extended buffer,
+// so the buffer can be reused for snapshots taken every frame
+func (chip *Chip8) AppendSnapshot(dst []byte) ([]byte, error) {
+	header := snapshotHeader{Version: SNAPSHOT_VERSION, Size: uint32(snapshotSize)}
+	copy(header.Magic[:], SNAPSHOT_MAGIC)
+
+	head := snapshotHead{
+		Ver:    int32(chip.Ver),
+		Quirks: chip.Quirks,
+	}
+	tail := snapshotTail{
+		Hires:            chip.Hires,
+		Planes:           chip.Planes,
+		AudioPattern:     chip.AudioPattern,
+		AudioPitch:       chip.AudioPitch,
+		Keyboard:         chip.Keyboard,
+		KeyLatch:         int8(chip.keyLatch),
+		Reg:              chip.Reg,
+		RomSize:          chip.RomSize,
+		Rng:              chip.rng,
+		RPL:              chip.RPL,
+		Running:          chip.State.Running,
+		Paused:           chip.State.Paused,
+		WaitingForVBlank: chip.State.WaitingForVBlank,
+		WaitingForKey:    chip.State.WaitingForKey,
+	}
+
+	start := len(dst)
+	buf := bytes.NewBuffer(dst)
+	if err := binary.Write(buf, binary.LittleEndian, header); err != nil {
+		return dst, err
+	}
+	if err := binary.Write(buf, binary.LittleEndian, head); err != nil {
+		return dst, err
+	}
+	buf.Write(chip.Memory[:])
+	buf.Write(chip.DisplayBuffer[:])
+	if err := binary.Write(buf, binary.LittleEndian, tail); err != nil {
+		return dst, err
+	}
+
+	crc := crc32.ChecksumIEEE(buf.Bytes()[start:])
+	return binary.LittleEndian.AppendUint32(buf.Bytes(), crc), nil
+}
+
+// Restore loads the machine state saved by Snapshot, the state is not changed on error
+func (chip *Chip8) Restore(data []byte) error {
+	var header snapshotHeader
+	headerSize := binary.Size(header)
+
+	if len(data) < headerSize+4 {
+		return ErrSnapshotFormat
+	}
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, &header); err != nil {
+		return err
+	}
+	if string(header.Magic[:]) != SNAPSHOT_MAGIC {
+		return ErrSnapshotFormat
+	}
+	if header.Version != SNAPSHOT_VERSION {
+		return ErrSnapshotVersion
+	}
+
+	if int(header.Size) != snapshotSize || len(data) != headerSize+int(header.Size)+4 {
+		return ErrSnapshotFormat
+	}
+
+	payloadEnd := headerSize + int(header.Size)
+	if crc32.ChecksumIEEE(data[:payloadEnd]) != binary.LittleEndian.Uint32(data[payloadEnd:]) {
+		return ErrSnapshotChecksum
+	}
+
+	var head snapshotHead
+	var tail snapshotTail
+	payload := data[headerSize:payloadEnd]
+	if err := binary.Read(bytes.NewReader(payload), binary.LittleEndian, &head); err != nil {
+		return err
+	}
+	if err := binary.Read(bytes.NewReader(payload[len(payload)-binary.Size(&tail):]), binary.LittleEndian, &tail); err != nil {
+		return err
+	}
+	memory := payload[binary.Size(&head):]
+
+	// nesting limit is not stored, the platform one is taken if the platform is switched
+	if ver := ChipVersion(head.Ver); ver != chip.Ver {
+		chip.Ver = ver
+		chip.MaxStackDepth = DefaultStackDepth(ver)
+	}
+	chip.Quirks = head.Quirks
+	copy(chip.Memory[:], memory)
+	copy(chip.DisplayBuffer[:], memory[len(chip.Memory):])
+	chip.Hires = tail.Hires
+	chip.Planes = tail.Planes
+	chip.AudioPattern = tail.AudioPattern
+	chip.AudioPitch = tail.AudioPitch
+	chip.Keyboard = tail.Keyboard
+	chip.keyLatch = int(tail.KeyLatch)
+	chip.Reg = tail.Reg
+	chip.RomSize = tail.RomSize
+	chip.rng = tail.Rng
+	chip.RPL = tail.RPL
+	chip.State.Running = tail.Running
+	chip.State.Paused = tail.Paused
+	chip.State.WaitingForVBlank = tail.WaitingForVBlank
+	chip.State.WaitingForKey = tail.WaitingForKey
+	chip.State.Err = nil
+	chip.State.Watch = nil
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package chip8_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brus-fabrika/chip8/chip8"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	ch := chip8.Chip8{}
+	ch.Init(chip8.Super_Chip_Modern)
+	ch.Seed(0x1234)
+
+	// RND V1, ff; DRAW 5, V1, V1; JMP 0x0200
+	ch.LoadRomFromData([]uint8{0xc1, 0xff, 0xd1, 0x15, 0x12, 0x00})
+	ch.Reg.T0 = 10
+	ch.Keyboard[3] = true
+	ch.RunFrame(5)
+
+	data, err := ch.Snapshot()
+	assert.NoError(t, err)
+
+	ch.RunFrame(5)
+	after := ch
+
+	// restored machine continues exactly the same way, random numbers included
+	ch2 := chip8.Chip8{}
+	ch2.Init(chip8.Chip_8)
+	if assert.NoError(t, ch2.Restore(data)) {
+		ch2.RunFrame(5)
+		assert.Equal(t, after.Ver, ch2.Ver)
+		assert.Equal(t, chip8.MAX_STACK_DEPTH, ch2.MaxStackDepth)
+		assert.Equal(t, after.Quirks, ch2.Quirks)
+		assert.Equal(t, after.Reg, ch2.Reg)
+		assert.Equal(t, after.Keyboard, ch2.Keyboard)
+		assert.Equal(t, after.Memory, ch2.Memory)
+		assert.Equal(t, after.DisplayBuffer, ch2.DisplayBuffer)
+	}
+}
+
+func TestAppendSnapshot(t *testing.T) {
+	ch := chip8.Chip8{}
+	ch.Init(chip8.XO_Chip)
+	ch.LoadRomFromData([]uint8{0xc1, 0xff, 0xd1, 0x15, 0x12, 0x00})
+	ch.RunFrame(5)
+
+	data, err := ch.Snapshot()
+	assert.NoError(t, err)
+
+	buf := make([]byte, 0, len(data))
+	buf, err = ch.AppendSnapshot(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, data, buf)
+
+	// buffer is reused, nothing allocated for it
+	again, err := ch.AppendSnapshot(buf[:0])
+	assert.NoError(t, err)
+	assert.Equal(t, data, again)
+	assert.Same(t, &buf[0], &again[0])
+
+	prefixed, err := ch.AppendSnapshot([]byte("prefix"))
+	assert.NoError(t, err)
+	assert.Equal(t, append([]byte("prefix"), data...), prefixed)
+}
+
+func TestRestoreErrors(t *testing.T) {
+	ch := chip8.Chip8{}
+	ch.Init(chip8.Chip_8)
+
+	data, _ := ch.Snapshot()
+
+	corrupted := append([]byte{}, data...)
+	corrupted[0x300] ^= 0xff
+	assert.ErrorIs(t, ch.Restore(corrupted), chip8.ErrSnapshotChecksum)
+
+	otherVersion := append([]byte{}, data...)
+	otherVersion[4]++
+	assert.ErrorIs(t, ch.Restore(otherVersion), chip8.ErrSnapshotVersion)
+
+	assert.ErrorIs(t, ch.Restore(data[:len(data)-1]), chip8.ErrSnapshotFormat)
+	assert.ErrorIs(t, ch.Restore([]byte("hello")), chip8.ErrSnapshotFormat)
+
+	assert.NoError(t, ch.Restore(data))
+}
@@ -0,0 +1,60 @@
+package chip8
+
+const (
+	STACK_SIZE          = 0x0030 // stack area size, 24 return addresses
+	MAX_STACK_DEPTH_VIP = 12     // COSMAC VIP interpreter nesting limit
+	MAX_STACK_DEPTH     = 16     // SCHIP and XO-CHIP nesting limit
+)
+
+var stackDepthPresets = map[ChipVersion]int{
+	Chip_8:            MAX_STACK_DEPTH_VIP,
+	Super_Chip_Modern: MAX_STACK_DEPTH,
+	Super_Chip_Legacy: MAX_STACK_DEPTH,
+	XO_Chip:           MAX_STACK_DEPTH,
+}
+
+// DefaultStackDepth returns subroutines nesting limit of the platform
+func DefaultStackDepth(ver ChipVersion) int {
+	return stackDepthPresets[ver]
+}
+
+// Frame is a subroutine call found on the stack
+type Frame struct {
+	Caller uint16 // address of the CALL instruction, the return address is the next one
+	Entry  uint16 // called subroutine address
+}
+
+// stackTop returns the last byte of the stack area, SP of the empty stack
+func (chip *Chip8) stackTop() uint16 {
+	return chip.StackBase() + STACK_SIZE - 1
+}
+
+// StackDepth returns the number of return addresses on the stack
+func (chip *Chip8) StackDepth() int {
+	if chip.Reg.SP >= chip.stackTop() {
+		return 0
+	}
+	return int(chip.stackTop()-chip.Reg.SP) / 2
+}
+
+// StackLimit returns the effective nesting limit: the configured one, which could not exceed the stack area,
+// or the whole stack area if the limit is off (MaxStackDepth <= 0)
+func (chip *Chip8) StackLimit() int {
+	if chip.MaxStackDepth <= 0 || chip.MaxStackDepth > STACK_SIZE/2 {
+		return STACK_SIZE / 2
+	}
+	return chip.MaxStackDepth
+}
+
+// CallStack decodes the stack into frames, the innermost first
+func (chip *Chip8) CallStack() []Frame {
+	mem := chip.Memory[:chip.MemorySize()]
+
+	frames := make([]Frame, 0, chip.StackDepth())
+	for adr := int(chip.Reg.SP) + 1; adr+1 <= int(chip.stackTop()); adr += 2 {
+		caller := uint16(mem[adr])<<8 + uint16(mem[adr+1])
+		frames = append(frames, Frame{Caller: caller, Entry: DecodeAt(mem, int(caller)).NNN})
+	}
+
+	return frames
+}
@@ -0,0 +1,61 @@
+package chip8_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brus-fabrika/chip8/chip8"
+)
+
+func TestCallStack(t *testing.T) {
+	for _, ver := range []chip8.ChipVersion{chip8.Chip_8, chip8.XO_Chip} {
+		ch := chip8.Chip8{}
+		ch.Init(ver)
+		// CALL 0x0204; 0000; CALL 0x0208; 0000; RET
+		ch.LoadRomFromData([]uint8{0x22, 0x04, 0x00, 0x00, 0x22, 0x08, 0x00, 0x00, 0x00, 0xee})
+
+		assert.Empty(t, ch.CallStack())
+		assert.Equal(t, 0, ch.StackDepth())
+
+		ch.Step()
+		ch.Step()
+		assert.Equal(t, uint16(0x0208), ch.Reg.PC)
+		assert.Equal(t, 2, ch.StackDepth())
+		assert.Equal(t, []chip8.Frame{{Caller: 0x0204, Entry: 0x0208}, {Caller: 0x0200, Entry: 0x0204}}, ch.CallStack())
+
+		ch.Step()
+		assert.Equal(t, []chip8.Frame{{Caller: 0x0200, Entry: 0x0204}}, ch.CallStack())
+	}
+}
+
+func TestDefaultStackDepth(t *testing.T) {
+	assert.Equal(t, chip8.MAX_STACK_DEPTH_VIP, chip8.DefaultStackDepth(chip8.Chip_8))
+	assert.Equal(t, chip8.MAX_STACK_DEPTH, chip8.DefaultStackDepth(chip8.Super_Chip_Legacy))
+
+	ch := chip8.Chip8{}
+	ch.Init(chip8.Super_Chip_Modern)
+	assert.Equal(t, chip8.MAX_STACK_DEPTH, ch.MaxStackDepth)
+}
+
+func TestStackLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxDepth int
+		limit    int
+	}{
+		{"Preset", chip8.MAX_STACK_DEPTH_VIP, chip8.MAX_STACK_DEPTH_VIP},
+		{"Off", 0, chip8.STACK_SIZE / 2},
+		{"Unlimited", -1, chip8.STACK_SIZE / 2},
+		{"OverStackArea", 100, chip8.STACK_SIZE / 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ch := chip8.Chip8{}
+			ch.Init(chip8.Chip_8)
+			ch.MaxStackDepth = tt.maxDepth
+			assert.Equal(t, tt.limit, ch.StackLimit())
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package chip8
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// TraceEvent describes one executed instruction
+type TraceEvent struct {
+	PC          uint16
+	Opcode      uint16
+	Instruction Instruction
+	Before      RegisterSet // registers before the execution
+	After       RegisterSet // registers after the execution
+	Err         error       // *ExecError if instruction failed
+}
+
+// Tracer receives every instruction executed by ProcessCmd. Event is valid only during the call.
+type Tracer interface {
+	Trace(ev *TraceEvent)
+}
+
+// NullTracer ignores everything, i.e. to switch tracing off in FilterTracer
+type NullTracer struct{}
+
+func (NullTracer) Trace(ev *TraceEvent) {}
+
+// TextTracer prints instructions in the listing form: address, opcode and assembler syntax
+type TextTracer struct {
+	W io.Writer
+}
+
+func (t TextTracer) Trace(ev *TraceEvent) {
+	if ev.Err != nil {
+		fmt.Fprintf(t.W, "\t%04x:\t%04x\t;%s\t; %v\n", ev.PC, ev.Opcode, ev.Instruction, ev.Err)
+		return
+	}
+	fmt.Fprintf(t.W, "\t%04x:\t%04x\t;%s\n", ev.PC, ev.Opcode, ev.Instruction)
+}
+
+// JSONLTracer writes every instruction as JSON object on its own line (JSON Lines)
+type JSONLTracer struct {
+	enc    *json.Encoder
+	closer io.Closer
+}
+
+type jsonlRegisters struct {
+	PC uint16    `json:"pc"`
+	SP uint16    `json:"sp"`
+	I  uint16    `json:"i"`
+	T0 uint8     `json:"t0"`
+	T1 uint8     `json:"t1"`
+	V  [16]uint8 `json:"v"`
+}
+
+type jsonlEvent struct {
+	PC          uint16         `json:"pc"`
+	Opcode      string         `json:"opcode"`
+	Instruction string         `json:"instruction"`
+	Before      jsonlRegisters `json:"before"`
+	After       jsonlRegisters `json:"after"`
+	Err         string         `json:"error,omitempty"`
+}
+
+func NewJSONLTracer(w io.Writer) *JSONLTracer {
+	return &JSONLTracer{enc: json.NewEncoder(w)}
+}
+
+// NewJSONLFileTracer creates trace file, it should be closed with Close
+func NewJSONLFileTracer(fileName string) (*JSONLTracer, error) {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	t := NewJSONLTracer(file)
+	t.closer = file
+	return t, nil
+}
+
+func (t *JSONLTracer) Trace(ev *TraceEvent) {
+	jev := jsonlEvent{
+		PC:          ev.PC,
+		Opcode:      fmt.Sprintf("%04x", ev.Opcode),
+		Instruction: ev.Instruction.String(),
+		Before:      jsonlRegisters(ev.Before),
+		After:       jsonlRegisters(ev.After),
+	}
+	if ev.Err != nil {
+		jev.Err = ev.Err.Error()
+	}
+
+	// tracing must not break the execution, write errors are ignored
+	t.enc.Encode(jev)
+}
+
+func (t *JSONLTracer) Close() error {
+	if t.closer != nil {
+		return t.closer.Close()
+	}
+	return nil
+}
+
+// OpClass groups instructions for trace filtering
+type OpClass int
+
+const (
+	ClassFlow    OpClass = 1 << iota // jumps, calls, returns, exit
+	ClassSkip                        // conditional skips
+	ClassALU                         // register arithmetic and logic, random
+	ClassMemory                      // I register, memory load and store, flags
+	ClassDisplay                     // drawing, clearing, scrolling, resolution and planes
+	ClassTimer                       // delay and sound timers, audio
+	ClassKey                         // keypad
+
+	ClassAll OpClass = 1<<iota - 1
+)
+
+var opClasses = map[OpKind]OpClass{
+	OpSys: ClassFlow, OpJmp: ClassFlow, OpCall: ClassFlow, OpRet: ClassFlow, OpJmpV: ClassFlow, OpExit: ClassFlow,
+
+	OpSeVal: ClassSkip, OpSneVal: ClassSkip, OpSeReg: ClassSkip, OpSneReg: ClassSkip,
+
+	OpMovVal: ClassALU, OpAddVal: ClassALU, OpMovReg: ClassALU, OpOr: ClassALU, OpAnd: ClassALU, OpXor: ClassALU,
+	OpAddReg: ClassALU, OpSubReg: ClassALU, OpShr: ClassALU, OpSubNReg: ClassALU, OpShl: ClassALU, OpRnd: ClassALU,
+
+	OpMovI: ClassMemory, OpAddI: ClassMemory, OpStc: ClassMemory, OpStcBig: ClassMemory, OpBcd: ClassMemory,
+	OpCam: ClassMemory, OpCar: ClassMemory, OpSaveFlags: ClassMemory, OpLoadFlags: ClassMemory,
+	OpSaveRange: ClassMemory, OpLoadRange: ClassMemory, OpMovILong: ClassMemory,
+
+	OpCls: ClassDisplay, OpDraw: ClassDisplay, OpScd: ClassDisplay, OpScu: ClassDisplay, OpScr: ClassDisplay,
+	OpScl: ClassDisplay, OpLow: ClassDisplay, OpHigh: ClassDisplay, OpPlane: ClassDisplay,
+
+	OpMovRegT0: ClassTimer, OpMovT0Reg: ClassTimer, OpMovT1Reg: ClassTimer, OpAudio: ClassTimer, OpPitch: ClassTimer,
+
+	OpSkp: ClassKey, OpSknp: ClassKey, OpKey: ClassKey,
+}
+
+// Class returns instruction class, invalid instructions have no class
+func (op OpKind) Class() OpClass {
+	return opClasses[op]
+}
+
+// FilterTracer passes to Next only instructions in the address range (From-To inclusive) of the classes.
+// Zero From and To mean any address, zero Classes means any class. Failed instructions are always passed.
+type FilterTracer struct {
+	Next     Tracer
+	From, To uint16
+	Classes  OpClass
+}
+
+func (t *FilterTracer) Trace(ev *TraceEvent) {
+	if ev.Err == nil {
+		if (t.From != 0 || t.To != 0) && (ev.PC < t.From || ev.PC > t.To) {
+			return
+		}
+		if t.Classes != 0 && ev.Instruction.Op.Class()&t.Classes == 0 {
+			return
+		}
+	}
+
+	t.Next.Trace(ev)
+}
+
+var opClassNames = map[string]OpClass{
+	"flow": ClassFlow, "skip": ClassSkip, "alu": ClassALU, "memory": ClassMemory,
+	"display": ClassDisplay, "timer": ClassTimer, "key": ClassKey, "all": ClassAll,
+}
+
+// ParseOpClasses parses comma separated class names: flow, skip, alu, memory, display, timer, key or all
+func ParseOpClasses(spec string) (OpClass, error) {
+	var classes OpClass
+
+	for _, name := range strings.Split(spec, ",") {
+		class, ok := opClassNames[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return 0, fmt.Errorf("unknown instruction class %q", name)
+		}
+		classes |= class
+	}
+
+	return classes, nil
+}
@@ -0,0 +1,106 @@
+package chip8_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brus-fabrika/chip8/chip8"
+)
+
+type recordTracer struct {
+	events []chip8.TraceEvent
+}
+
+func (t *recordTracer) Trace(ev *chip8.TraceEvent) {
+	t.events = append(t.events, *ev)
+}
+
+func TestTracer(t *testing.T) {
+	ch := chip8.Chip8{}
+	ch.Init(chip8.Chip_8)
+	rec := &recordTracer{}
+	ch.Tracer = rec
+
+	// MOV V1, 0a; invalid
+	ch.LoadRomFromData([]uint8{0x61, 0x0a, 0xff, 0xff})
+	ch.Step()
+	ch.Step()
+
+	if assert.Len(t, rec.events, 2) {
+		ev := rec.events[0]
+		assert.Equal(t, uint16(0x0200), ev.PC)
+		assert.Equal(t, uint16(0x610a), ev.Opcode)
+		assert.Equal(t, chip8.OpMovVal, ev.Instruction.Op)
+		assert.Equal(t, uint8(0x00), ev.Before.V[1])
+		assert.Equal(t, uint8(0x0a), ev.After.V[1])
+		assert.Equal(t, uint16(0x0202), ev.After.PC)
+		assert.NoError(t, ev.Err)
+
+		assert.ErrorIs(t, rec.events[1].Err, chip8.ErrInvalidOpcode)
+	}
+}
+
+func TestTextTracer(t *testing.T) {
+	var sb strings.Builder
+	ch := chip8.Chip8{}
+	ch.Init(chip8.Chip_8)
+	ch.Tracer = chip8.TextTracer{W: &sb}
+
+	ch.ProcessCmd(0x610a)
+	assert.Equal(t, "\t0200:\t610a\t;MOV V1, 0a\n", sb.String())
+}
+
+func TestJSONLTracer(t *testing.T) {
+	var sb strings.Builder
+	ch := chip8.Chip8{}
+	ch.Init(chip8.Chip_8)
+	ch.Tracer = chip8.NewJSONLTracer(&sb)
+
+	ch.ProcessCmd(0x610a)
+	ch.ProcessCmd(0xa123)
+
+	lines := strings.Split(strings.TrimSpace(sb.String()), "\n")
+	if assert.Len(t, lines, 2) {
+		var ev map[string]any
+		if assert.NoError(t, json.Unmarshal([]byte(lines[0]), &ev)) {
+			assert.Equal(t, "610a", ev["opcode"])
+			assert.Equal(t, "MOV V1, 0a", ev["instruction"])
+			assert.Equal(t, float64(0x0200), ev["pc"])
+			assert.Equal(t, float64(0x0202), ev["after"].(map[string]any)["pc"])
+			assert.NotContains(t, ev, "error")
+		}
+	}
+}
+
+func TestFilterTracer(t *testing.T) {
+	ch := chip8.Chip8{}
+	ch.Init(chip8.Chip_8)
+	rec := &recordTracer{}
+
+	classes, err := chip8.ParseOpClasses("flow, display")
+	assert.NoError(t, err)
+	ch.Tracer = &chip8.FilterTracer{Next: rec, From: 0x0202, To: 0x0206, Classes: classes}
+
+	// CLS; CLS; MOV V1, 0a; JMP 0x0208; CLS
+	ch.LoadRomFromData([]uint8{0x00, 0xe0, 0x00, 0xe0, 0x61, 0x0a, 0x12, 0x08, 0x00, 0xe0})
+	for i := 0; i < 5; i++ {
+		ch.Step()
+	}
+
+	if assert.Len(t, rec.events, 2) {
+		assert.Equal(t, uint16(0x0202), rec.events[0].PC)
+		assert.Equal(t, uint16(0x0206), rec.events[1].PC)
+	}
+
+	_, err = chip8.ParseOpClasses("flow,jumps")
+	assert.Error(t, err)
+}
+
+func TestOpcodesClasses(t *testing.T) {
+	for _, info := range chip8.Opcodes {
+		assert.NotZero(t, info.Op.Class(), info.Code)
+	}
+}
@@ -0,0 +1,129 @@
+package chip8
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// WatchAccess is a set of memory access kinds checked by watchpoint
+type WatchAccess uint8
+
+const (
+	WatchRead  WatchAccess = 1 << iota // instruction reads data: sprites, stack, loads
+	WatchWrite                         // instruction writes data: stack, BCD, stores
+	WatchExec                          // instruction is located in the range
+)
+
+func (a WatchAccess) String() string {
+	s := ""
+	if a&WatchRead != 0 {
+		s += "r"
+	}
+	if a&WatchWrite != 0 {
+		s += "w"
+	}
+	if a&WatchExec != 0 {
+		s += "x"
+	}
+	return s
+}
+
+// Watchpoint triggers on Access to memory From-To (inclusive)
+type Watchpoint struct {
+	From, To uint16
+	Access   WatchAccess
+}
+
+func (wp Watchpoint) String() string {
+	if wp.From == wp.To {
+		return fmt.Sprintf("%s:%04x", wp.Access, wp.From)
+	}
+	return fmt.Sprintf("%s:%04x-%04x", wp.Access, wp.From, wp.To)
+}
+
+// ParseWatchpoint parses "access:from[-to]" spec, access is any of r, w and x letters, addresses are hex.
+// I.e. "w:0ea0-0eaf" for writes to the stack or "rw:0300" for a game variable.
+func ParseWatchpoint(spec string) (Watchpoint, error) {
+	wp := Watchpoint{}
+
+	access, addrs, ok := strings.Cut(strings.ToLower(strings.TrimSpace(spec)), ":")
+	if !ok || access == "" {
+		return wp, fmt.Errorf("invalid watchpoint %q, expected access:from[-to]", spec)
+	}
+
+	for _, c := range access {
+		switch c {
+		case 'r':
+			wp.Access |= WatchRead
+		case 'w':
+			wp.Access |= WatchWrite
+		case 'x':
+			wp.Access |= WatchExec
+		default:
+			return wp, fmt.Errorf("invalid watchpoint access %q, expected r, w or x", access)
+		}
+	}
+
+	from, to, isRange := strings.Cut(addrs, "-")
+	if !isRange {
+		to = from
+	}
+	fromVal, err1 := strconv.ParseUint(strings.TrimPrefix(from, "0x"), 16, 16)
+	toVal, err2 := strconv.ParseUint(strings.TrimPrefix(to, "0x"), 16, 16)
+	if err1 != nil || err2 != nil || toVal < fromVal {
+		return wp, fmt.Errorf("invalid watchpoint range %q", addrs)
+	}
+	wp.From, wp.To = uint16(fromVal), uint16(toVal)
+
+	return wp, nil
+}
+
+// WatchHit describes the instruction which triggered the watchpoint
+type WatchHit struct {
+	Watchpoint  Watchpoint
+	Adr         uint16      // first accessed address inside the watchpoint
+	Access      WatchAccess // actual access kind
+	PC          uint16
+	Opcode      uint16
+	Instruction Instruction
+}
+
+func (hit *WatchHit) String() string {
+	return fmt.Sprintf("watchpoint %s: %s %04x by %04x: %04x\t%s", hit.Watchpoint, hit.Access, hit.Adr, hit.PC, hit.Opcode, hit.Instruction)
+}
+
+// watch records n bytes access at adr into coverage and checks it against watchpoints,
+// the first hit during instruction is kept
+func (chip *Chip8) watch(adr, n int, access WatchAccess) {
+	if chip.Coverage != nil {
+		chip.Coverage.mark(adr, n, access)
+	}
+
+	if len(chip.Watchpoints) == 0 || chip.watchHit != nil || n <= 0 {
+		return
+	}
+
+	end := adr + n - 1
+	for _, wp := range chip.Watchpoints {
+		if wp.Access&access == 0 || end < int(wp.From) || adr > int(wp.To) {
+			continue
+		}
+		chip.watchHit = &WatchHit{Watchpoint: wp, Adr: uint16(max(adr, int(wp.From))), Access: access}
+		return
+	}
+}
+
+// reportWatch pauses the machine after the instruction which triggered watchpoint
+func (chip *Chip8) reportWatch(pc, opcode uint16, in Instruction) {
+	hit := chip.watchHit
+	chip.watchHit = nil
+
+	hit.PC, hit.Opcode, hit.Instruction = pc, opcode, in
+	chip.State.Watch = hit
+	chip.State.Paused = true
+
+	if chip.OnWatch != nil {
+		chip.OnWatch(hit)
+	}
+}
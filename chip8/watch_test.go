@@ -0,0 +1,97 @@
+package chip8_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brus-fabrika/chip8/chip8"
+)
+
+func TestParseWatchpoint(t *testing.T) {
+	testCases := []struct {
+		spec string
+		wp   chip8.Watchpoint
+	}{
+		{"w:0ea0-0eaf", chip8.Watchpoint{From: 0x0ea0, To: 0x0eaf, Access: chip8.WatchWrite}},
+		{"RW:0x0300", chip8.Watchpoint{From: 0x0300, To: 0x0300, Access: chip8.WatchRead | chip8.WatchWrite}},
+		{"x:0200-02ff", chip8.Watchpoint{From: 0x0200, To: 0x02ff, Access: chip8.WatchExec}},
+	}
+
+	for _, tc := range testCases {
+		wp, err := chip8.ParseWatchpoint(tc.spec)
+		assert.NoError(t, err, tc.spec)
+		assert.Equal(t, tc.wp, wp, tc.spec)
+	}
+
+	for _, spec := range []string{"0300", "q:0300", "r:", "r:0300-0200", "r:10000"} {
+		_, err := chip8.ParseWatchpoint(spec)
+		assert.Error(t, err, spec)
+	}
+
+	wp, _ := chip8.ParseWatchpoint("rwx:0300-030f")
+	assert.Equal(t, "rwx:0300-030f", wp.String())
+}
+
+func TestWatchpoints(t *testing.T) {
+	// MOV I, 0x0300; MOV V0, 7b; BCD V0; CAR V1; CALL 0x020e; CLS; RET
+	rom := []uint8{0xa3, 0x00, 0x60, 0x7b, 0xf0, 0x33, 0xf1, 0x65, 0x22, 0x0e, 0x00, 0x00, 0x00, 0x00, 0x00, 0xe0, 0x00, 0xee}
+
+	testCases := []struct {
+		name   string
+		wp     chip8.Watchpoint
+		pc     uint16
+		access chip8.WatchAccess
+		adr    uint16
+	}{
+		{"bcd write", chip8.Watchpoint{From: 0x0302, To: 0x0302, Access: chip8.WatchWrite}, 0x0204, chip8.WatchWrite, 0x0302},
+		{"load read", chip8.Watchpoint{From: 0x0300, To: 0x03ff, Access: chip8.WatchRead}, 0x0206, chip8.WatchRead, 0x0300},
+		{"stack write", chip8.Watchpoint{From: 0x0ea0, To: 0x0eff, Access: chip8.WatchWrite}, 0x0208, chip8.WatchWrite, 0x0ece},
+		{"stack read", chip8.Watchpoint{From: 0x0ea0, To: 0x0eff, Access: chip8.WatchRead}, 0x0210, chip8.WatchRead, 0x0ece},
+		{"execute", chip8.Watchpoint{From: 0x020e, To: 0x020f, Access: chip8.WatchExec}, 0x020e, chip8.WatchExec, 0x020e},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ch := chip8.Chip8{}
+			ch.Init(chip8.Chip_8)
+			ch.LoadRomFromData(rom)
+			ch.Watchpoints = []chip8.Watchpoint{tc.wp}
+
+			var hits []*chip8.WatchHit
+			ch.OnWatch = func(hit *chip8.WatchHit) { hits = append(hits, hit) }
+
+			for i := 0; i < 10 && !ch.State.Paused; i++ {
+				assert.NoError(t, ch.Step())
+			}
+
+			assert.True(t, ch.State.Paused)
+			if assert.Len(t, hits, 1) {
+				hit := hits[0]
+				assert.Equal(t, tc.pc, hit.PC)
+				assert.Equal(t, tc.access, hit.Access)
+				assert.Equal(t, tc.adr, hit.Adr)
+				assert.Equal(t, hit, ch.State.Watch)
+			}
+		})
+	}
+}
+
+func TestWatchpointsIgnored(t *testing.T) {
+	ch := chip8.Chip8{}
+	ch.Init(chip8.Chip_8)
+	ch.Watchpoints = []chip8.Watchpoint{{From: 0x0300, To: 0x0300, Access: chip8.WatchRead | chip8.WatchExec}}
+
+	// write to the watched address and read next to it
+	ch.LoadRomFromData([]uint8{0xa3, 0x00, 0xf0, 0x55, 0xa3, 0x01, 0xf0, 0x65})
+	for i := 0; i < 4; i++ {
+		ch.Step()
+	}
+	assert.False(t, ch.State.Paused)
+	assert.Nil(t, ch.State.Watch)
+
+	// failed instruction is not reported
+	ch.Reg.PC = 0x0300
+	assert.Error(t, ch.Step())
+	assert.Nil(t, ch.State.Watch)
+}
@@ -0,0 +1,100 @@
+package chip8
+
+import "math"
+
+// xoPlatforms are the platforms supporting XO-CHIP instructions
+var xoPlatforms = []ChipVersion{XO_Chip}
+
+// Size returns instruction length in bytes, XO-CHIP F000 NNNN is the only 4 bytes long one
+func (in Instruction) Size() uint16 {
+	if in.Op == OpMovILong {
+		return 4
+	}
+	return 2
+}
+
+// MovILong is XO-CHIP F000 NNNN, sets I to 16-bit address following the opcode
+func (chip *Chip8) MovILong(adr uint16) {
+	chip.Reg.I = adr
+	chip.Reg.PC += 4
+}
+
+// regRange returns registers indexes from x to y inclusive, in reverse order if x > y
+func regRange(x, y Register) []int {
+	step := 1
+	if x > y {
+		step = -1
+	}
+
+	regs := make([]int, 0, 16)
+	for r := int(x); ; r += step {
+		regs = append(regs, r)
+		if r == int(y) {
+			break
+		}
+	}
+
+	return regs
+}
+
+// CopyRegRangeToMem is XO-CHIP 5XY2, sets MI = VX:VY, I is not changed
+func (chip *Chip8) CopyRegRangeToMem(x, y Register) error {
+	regs := regRange(x, y)
+	if int(chip.Reg.I)+len(regs) > chip.MemorySize() {
+		return ErrMemoryOutOfBounds
+	}
+
+	chip.watch(int(chip.Reg.I), len(regs), WatchWrite)
+	for i, r := range regs {
+		chip.Memory[int(chip.Reg.I)+i] = chip.Reg.V[r]
+	}
+
+	chip.Reg.PC += 2
+	return nil
+}
+
+// CopyMemToRegRange is XO-CHIP 5XY3, sets VX:VY = MI, I is not changed
+func (chip *Chip8) CopyMemToRegRange(x, y Register) error {
+	regs := regRange(x, y)
+	if int(chip.Reg.I)+len(regs) > chip.MemorySize() {
+		return ErrMemoryOutOfBounds
+	}
+
+	chip.watch(int(chip.Reg.I), len(regs), WatchRead)
+	for i, r := range regs {
+		chip.Reg.V[r] = chip.Memory[int(chip.Reg.I)+i]
+	}
+
+	chip.Reg.PC += 2
+	return nil
+}
+
+// SetPlanes is XO-CHIP FN01, selects drawing planes by mask N (0-3)
+func (chip *Chip8) SetPlanes(n uint8) {
+	chip.Planes = n & 0x03
+	chip.Reg.PC += 2
+}
+
+// LoadAudioPattern is XO-CHIP F002, loads 16 bytes audio pattern from MI
+func (chip *Chip8) LoadAudioPattern() error {
+	if int(chip.Reg.I)+len(chip.AudioPattern) > chip.MemorySize() {
+		return ErrMemoryOutOfBounds
+	}
+
+	chip.watch(int(chip.Reg.I), len(chip.AudioPattern), WatchRead)
+	copy(chip.AudioPattern[:], chip.Memory[chip.Reg.I:])
+
+	chip.Reg.PC += 2
+	return nil
+}
+
+// SetPitchReg is XO-CHIP FX3A, sets audio pattern playback pitch
+func (chip *Chip8) SetPitchReg(r Register) {
+	chip.AudioPitch = chip.Reg.V[r]
+	chip.Reg.PC += 2
+}
+
+// AudioSampleRate returns audio pattern playback rate in samples (bits) per second
+func (chip *Chip8) AudioSampleRate() float64 {
+	return 4000 * math.Pow(2, (float64(chip.AudioPitch)-64)/48)
+}
@@ -0,0 +1,127 @@
+package chip8_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brus-fabrika/chip8/chip8"
+)
+
+func TestXoOpcodesPlatform(t *testing.T) {
+	ch := chip8.Chip8{}
+
+	for _, cmd := range []uint16{0x00d1, 0x5012, 0x5013, 0xf000, 0xf201, 0xf002, 0xf13a} {
+		ch.Init(chip8.Super_Chip_Modern)
+		assert.ErrorIs(t, ch.ProcessCmd(cmd), chip8.ErrInvalidOpcode, "%04x", cmd)
+
+		ch.Init(chip8.XO_Chip)
+		assert.NoError(t, ch.ProcessCmd(cmd), "%04x", cmd)
+	}
+}
+
+func TestXoMemory(t *testing.T) {
+	ch := chip8.Chip8{}
+
+	ch.Init(chip8.Chip_8)
+	assert.Equal(t, 0x1000, ch.MemorySize())
+
+	ch.Init(chip8.XO_Chip)
+	assert.Equal(t, 0x10000, ch.MemorySize())
+
+	// ROM could take the whole 64K above the user area
+	rom := make([]uint8, 0x10000-0x200)
+	_, err := ch.LoadRomFromData(rom)
+	assert.NoError(t, err)
+
+	ch.Reg.I = 0xfff0
+	ch.Reg.V[0] = 0x42
+	assert.NoError(t, ch.CopyRegToMem(chip8.RegV0))
+	assert.Equal(t, uint8(0x42), ch.Memory[0xfff0])
+}
+
+func TestMovILong(t *testing.T) {
+	ch := chip8.Chip8{}
+	ch.Init(chip8.XO_Chip)
+
+	// MOVL I, 0xabcd; SE V0, 00; MOVL I, 0x1234; MOV V1, 01
+	ch.LoadRomFromData([]uint8{0xf0, 0x00, 0xab, 0xcd, 0x30, 0x00, 0xf0, 0x00, 0x12, 0x34, 0x61, 0x01})
+
+	assert.NoError(t, ch.Step())
+	assert.Equal(t, uint16(0xabcd), ch.Reg.I)
+	assert.Equal(t, uint16(0x0204), ch.Reg.PC)
+
+	// skip jumps over the whole 4 bytes instruction
+	assert.NoError(t, ch.Step())
+	assert.Equal(t, uint16(0x020a), ch.Reg.PC)
+
+	in := chip8.DecodeAt(ch.Memory[:], 0x0200)
+	assert.Equal(t, uint16(4), in.Size())
+	assert.Equal(t, "MOVL I, 0xabcd", in.String())
+}
+
+func TestRegRange(t *testing.T) {
+	ch := chip8.Chip8{}
+	ch.Init(chip8.XO_Chip)
+	ch.Reg.I = 0x0300
+	ch.Reg.V = [16]uint8{0, 1, 2, 3, 4}
+
+	ch.ProcessCmd(0x5242) // CAMR V2, V4
+	assert.Equal(t, []uint8{2, 3, 4}, ch.Memory[0x0300:0x0303])
+	assert.Equal(t, uint16(0x0300), ch.Reg.I)
+
+	ch.ProcessCmd(0x5422) // CAMR V4, V2
+	assert.Equal(t, []uint8{4, 3, 2}, ch.Memory[0x0300:0x0303])
+
+	ch.Reg.V = [16]uint8{}
+	ch.ProcessCmd(0x5133) // CARR V1, V3
+	assert.Equal(t, [16]uint8{0, 4, 3, 2}, ch.Reg.V)
+	assert.Equal(t, uint16(0x0300), ch.Reg.I)
+}
+
+func TestPlanes(t *testing.T) {
+	ch := chip8.Chip8{}
+	ch.Init(chip8.XO_Chip)
+	ch.Memory[0x0300] = 0x80 // plane 1 data
+	ch.Memory[0x0301] = 0xc0 // plane 2 data
+	ch.Reg.I = 0x0300
+
+	ch.ProcessCmd(0xf301) // PLANE 3
+	ch.ProcessCmd(0xd011) // DRAW 1, V0, V0
+
+	assert.Equal(t, uint8(3), ch.PixelPlanes(0, 0))
+	assert.Equal(t, uint8(2), ch.PixelPlanes(1, 0))
+	assert.Equal(t, uint8(0), ch.Reg.V[0x0F])
+
+	// clear and scroll only selected planes
+	ch.ProcessCmd(0xf201) // PLANE 2
+	ch.ProcessCmd(0x00d1) // SCU 1
+	assert.Equal(t, uint8(1), ch.PixelPlanes(0, 0))
+	assert.Equal(t, uint8(0), ch.PixelPlanes(1, 0))
+
+	ch.ProcessCmd(0xf101) // PLANE 1
+	ch.ProcessCmd(0x00e0) // CLS
+	assert.False(t, ch.Pixel(0, 0))
+
+	// nothing is drawn with no planes selected
+	ch.ProcessCmd(0xf001)
+	ch.ProcessCmd(0xd011)
+	assert.False(t, ch.Pixel(0, 0))
+}
+
+func TestAudio(t *testing.T) {
+	ch := chip8.Chip8{}
+	ch.Init(chip8.XO_Chip)
+	assert.Equal(t, 4000.0, ch.AudioSampleRate())
+
+	for i := 0; i < 16; i++ {
+		ch.Memory[0x0300+i] = uint8(i)
+	}
+	ch.Reg.I = 0x0300
+	ch.ProcessCmd(0xf002) // AUDIO
+	assert.Equal(t, [16]uint8{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}, ch.AudioPattern)
+
+	ch.Reg.V[1] = 112
+	ch.ProcessCmd(0xf13a) // PITCH V1
+	assert.Equal(t, 8000.0, ch.AudioSampleRate())
+}
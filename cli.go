@@ -0,0 +1,260 @@
+package main
+
+import (
+	"crypto/sha1"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brus-fabrika/chip8/chip8"
+	"github.com/brus-fabrika/chip8/disasm"
+)
+
+type command struct {
+	Name string
+	Desc string
+	Run  func(args []string) error
+}
+
+// commands are chip8 subcommands, run is the default one, so "chip8 rom.ch8" works too
+var commands = []command{
+	{Name: "run", Desc: "run the ROM in the window, or in the terminal debugger with -debug", Run: RunCommand},
+	{Name: "disasm", Desc: "print the ROM listing", Run: DisasmCommand},
+	{Name: "info", Desc: "show the ROM size, code, data and the platform it needs", Run: InfoCommand},
+	{Name: "bench", Desc: "run the ROM headless as fast as possible and report the speed", Run: BenchCommand},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: chip8 [command] [flags] rom.ch8")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s%s\n", cmd.Name, cmd.Desc)
+	}
+	fmt.Fprintln(os.Stderr, "\nRun \"chip8 <command> -h\" for the command flags.")
+}
+
+// findCommand returns the command named by the first argument and the rest of arguments,
+// run command with all the arguments if there is no such name
+func findCommand(args []string) (command, []string) {
+	if len(args) > 0 {
+		for _, cmd := range commands {
+			if cmd.Name == args[0] {
+				return cmd, args[1:]
+			}
+		}
+	}
+	return commands[0], args
+}
+
+// newFlagSet creates the command flags, parsing errors and -h exit with usage
+func newFlagSet(cmd string) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: chip8 %s [flags] rom.ch8\n", cmd)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseArgs parses the command flags and returns the ROM file, the only positional argument
+func parseArgs(fs *flag.FlagSet, args []string) string {
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	return fs.Arg(0)
+}
+
+// machineFlags are the emulated machine settings shared by the commands
+type machineFlags struct {
+	Platform string
+	Quirks   string
+	IPF      int
+}
+
+func (m *machineFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&m.Platform, "platform", "chip8", "target platform: chip8, schip, schip-legacy or xo")
+	fs.StringVar(&m.Quirks, "quirks", "", "quirks override on top of the platform preset and <rom>.quirks file, i.e. -shift,vblank=0")
+	fs.IntVar(&m.IPF, "ipf", chip8.DEFAULT_CYCLES_PER_FRAME, "instructions per 60 Hz frame")
+}
+
+// newChip creates the machine with the ROM loaded, quirks are taken from the platform preset,
+// then from <rom>.quirks file next to the ROM (i.e. tetris.ch8.quirks) and the -quirks flag
+func (m *machineFlags) newChip(romFile string) (*chip8.Chip8, error) {
+	if m.IPF <= 0 {
+		return nil, fmt.Errorf("invalid ipf %d", m.IPF)
+	}
+
+	ver, err := chip8.ParseChipVersion(m.Platform)
+	if err != nil {
+		return nil, err
+	}
+
+	chip := &chip8.Chip8{}
+	chip.Init(ver)
+	chip.CyclesPerFrame = m.IPF
+
+	if err := chip.Quirks.LoadOverrideFile(romFile + ".quirks"); err == nil {
+		fmt.Println("Quirks overridden:", chip.Quirks)
+	}
+	if err := chip.Quirks.Override(m.Quirks); err != nil {
+		return nil, err
+	}
+
+	if _, err := chip.LoadRomFromFile(romFile); err != nil {
+		return nil, err
+	}
+
+	return chip, nil
+}
+
+// colorFlag is RGB color in hex, i.e. 00c800 or #00c800
+type colorFlag uint32
+
+func (c *colorFlag) String() string {
+	return fmt.Sprintf("%06x", uint32(*c))
+}
+
+func (c *colorFlag) Set(s string) error {
+	v, err := strconv.ParseUint(strings.TrimPrefix(s, "#"), 16, 24)
+	if err != nil {
+		return fmt.Errorf("invalid color %q, RGB hex expected", s)
+	}
+	*c = colorFlag(v)
+	return nil
+}
+
+func DisasmCommand(args []string) error {
+	fs := newFlagSet("disasm")
+	platform := fs.String("platform", "chip8", "target platform: chip8, schip, schip-legacy or xo")
+	outFile := fs.String("o", "", "output file, stdout by default")
+	romFile := parseArgs(fs, args)
+
+	return disasm.ListFile(romFile, *platform, *outFile)
+}
+
+func InfoCommand(args []string) error {
+	fs := newFlagSet("info")
+	var m machineFlags
+	m.register(fs)
+	romFile := parseArgs(fs, args)
+
+	chip, err := m.newChip(romFile)
+	if err != nil {
+		return err
+	}
+	rom := chip.Memory[chip8.MEMORY_USER : int(chip8.MEMORY_USER)+int(chip.RomSize)]
+
+	// traced for the widest platform, so the instructions of any platform are found
+	program := disasm.Trace(rom, chip8.XO_Chip)
+	instructions, codeSize, subs := 0, 0, 0
+	for _, line := range program.Lines() {
+		if !program.IsCode(line.Addr) {
+			continue
+		}
+		instructions++
+		codeSize += len(line.Bytes)
+		if strings.HasPrefix(line.Label, "sub_") {
+			subs++
+		}
+	}
+	needs, extensions := requiredPlatform(program)
+
+	fmt.Printf("ROM        : %s\n", romFile)
+	fmt.Printf("Size       : %d bytes, 0x%04x - 0x%04x\n", len(rom), chip8.MEMORY_USER, int(chip8.MEMORY_USER)+len(rom)-1)
+	fmt.Printf("SHA-1      : %x\n", sha1.Sum(rom))
+	fmt.Printf("Code       : %d instructions, %d bytes, %d subroutines\n", instructions, codeSize, subs)
+	fmt.Printf("Data       : %d bytes\n", len(rom)-codeSize)
+	if len(extensions) > 0 {
+		fmt.Printf("Needs      : %s (%s)\n", needs, strings.Join(extensions, ", "))
+	} else {
+		fmt.Printf("Needs      : %s\n", needs)
+	}
+	fmt.Printf("Platform   : %s\n", chip.Ver)
+	fmt.Printf("Quirks     : %s\n", chip.Quirks)
+	fmt.Printf("Stack depth: %d\n", chip.StackLimit())
+
+	memEnd := chip.MemorySize() - 1
+	stackBase := int(chip.StackBase())
+	stackEnd := stackBase + chip8.STACK_SIZE - 1
+
+	fmt.Println("Memory layout:")
+	if stackBase < int(chip8.MEMORY_USER) {
+		// XO-CHIP stack is in the interpreter area, user memory takes the rest of the address space
+		fmt.Printf("\tStack area       : 0x%x - 0x%x\n", stackBase, stackEnd)
+		fmt.Printf("\tUser memory      : 0x%x - 0x%x\n", chip8.MEMORY_USER, memEnd)
+	} else {
+		fmt.Printf("\tUser memory      : 0x%x - 0x%x\n", chip8.MEMORY_USER, stackBase-1)
+		fmt.Printf("\tStack area       : 0x%x - 0x%x\n", stackBase, stackEnd)
+		fmt.Printf("\tInterpreter  area: 0x%x - 0x%x\n", stackEnd+1, chip8.MEMORY_REG_AREA-1)
+		fmt.Printf("\tRegisters        : 0x%x - 0x%x\n", chip8.MEMORY_REG_AREA, chip8.MEMORY_DISPLAY-1)
+		fmt.Printf("\tUser memory start: 0x%x - 0x%x\n", chip8.MEMORY_DISPLAY, memEnd)
+	}
+
+	return nil
+}
+
+// requiredPlatform returns the least platform supporting all the traced instructions
+// and mnemonics of the instructions missing on CHIP-8
+func requiredPlatform(program *disasm.Program) (chip8.ChipVersion, []string) {
+	var used []*chip8.OpcodeInfo
+	var extensions []string
+	seen := map[string]bool{}
+
+	for _, line := range program.Lines() {
+		if !program.IsCode(line.Addr) {
+			continue
+		}
+		info := chip8.DecodeAt(line.Bytes, 0).Info()
+		used = append(used, info)
+		if !info.Supports(chip8.Chip_8) && !seen[info.Mnemonic()] {
+			seen[info.Mnemonic()] = true
+			extensions = append(extensions, info.Mnemonic())
+		}
+	}
+
+	for _, ver := range []chip8.ChipVersion{chip8.Chip_8, chip8.Super_Chip_Modern} {
+		supported := true
+		for _, info := range used {
+			supported = supported && info.Supports(ver)
+		}
+		if supported {
+			return ver, extensions
+		}
+	}
+
+	return chip8.XO_Chip, extensions
+}
+
+func BenchCommand(args []string) error {
+	fs := newFlagSet("bench")
+	var m machineFlags
+	m.register(fs)
+	frames := fs.Int("frames", 60*chip8.FRAME_RATE, "emulated frames to run, a minute by default")
+	romFile := parseArgs(fs, args)
+
+	chip, err := m.newChip(romFile)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	frame := 0
+	for ; frame < *frames && chip.State.Running; frame++ {
+		if err := chip.RunFrame(chip.CyclesPerFrame); err != nil {
+			return fmt.Errorf("frame %d: %w", frame, err)
+		}
+	}
+	elapsed := time.Since(start)
+
+	seconds := elapsed.Seconds()
+	fmt.Printf("%s: %d frames, %d instructions in %v\n", romFile, frame, chip.Cycles, elapsed)
+	fmt.Printf("%.0f frames/s (%.0fx realtime), %.0f instructions/s\n",
+		float64(frame)/seconds, float64(frame)/seconds/chip8.FRAME_RATE, float64(chip.Cycles)/seconds)
+
+	return nil
+}
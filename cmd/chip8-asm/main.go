@@ -0,0 +1,43 @@
+// chip8-asm assembles CHIP-8 program written in the emulator mnemonics into ROM
+//
+//	chip8-asm [-o rom.ch8] program.asm
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/brus-fabrika/chip8/asm"
+)
+
+func main() {
+	outFile := flag.String("o", "", "output ROM file, source name with .ch8 extension by default")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: chip8-asm [flags] program.asm")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	srcFile := flag.Arg(0)
+	if *outFile == "" {
+		*outFile = strings.TrimSuffix(srcFile, ".asm") + ".ch8"
+	}
+
+	assembler := asm.Assembler{}
+	rom, err := assembler.AssembleFile(srcFile)
+	if err == nil {
+		err = os.WriteFile(*outFile, rom, 0o644)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "chip8-asm:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,37 @@
+// chip8-dap is Debug Adapter Protocol server for CHIP-8 programs, VS Code and other DAP clients could use it
+//
+//	chip8-dap [-listen localhost:4711]
+//
+// The protocol goes over stdin/stdout unless TCP address to listen on is given.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/brus-fabrika/chip8/dap"
+)
+
+func main() {
+	listen := flag.String("listen", "", "TCP address to listen on, i.e. localhost:4711, stdin/stdout by default")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: chip8-dap [flags]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	server := dap.Server{}
+
+	var err error
+	if *listen != "" {
+		err = server.ListenAndServe(*listen)
+	} else {
+		err = server.Serve(os.Stdin, os.Stdout)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "chip8-dap:", err)
+		os.Exit(1)
+	}
+}
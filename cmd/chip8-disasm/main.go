@@ -0,0 +1,32 @@
+// chip8-disasm prints assembler listing of CHIP-8 ROM
+//
+//	chip8-disasm [-platform chip8|schip|schip-legacy|xo] [-o listing.asm] rom.ch8
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/brus-fabrika/chip8/disasm"
+)
+
+func main() {
+	platform := flag.String("platform", "chip8", "target platform: chip8, schip, schip-legacy or xo")
+	outFile := flag.String("o", "", "output file, stdout by default")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: chip8-disasm [flags] rom.ch8")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := disasm.ListFile(flag.Arg(0), *platform, *outFile); err != nil {
+		fmt.Fprintln(os.Stderr, "chip8-disasm:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,600 @@
+// Package dap is a Debug Adapter Protocol server, so VS Code and other DAP clients could debug CHIP-8 programs.
+//
+// Launch arguments are "program" (ROM or .asm source, which is assembled with the line information),
+// "platform" (chip8, schip, schip-legacy or xo) and "stopOnEntry".
+// Breakpoints are set by source lines of the assembled program or by instruction addresses.
+// The call stack is read from the stack area, the registers are the only variables scope.
+// REPL expressions are register names or the terminal debugger inspection commands, "set" and "key",
+// i.e. "key 5" to press a keypad key. The machine is run by the client controls only.
+package dap
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/brus-fabrika/chip8/asm"
+	"github.com/brus-fabrika/chip8/chip8"
+	"github.com/brus-fabrika/chip8/debugger"
+)
+
+const (
+	THREAD_ID     = 1 // the machine is the only thread
+	REGISTERS_REF = 1 // variables reference of the registers scope
+)
+
+var (
+	errPause      = errors.New("paused")
+	errDisconnect = errors.New("disconnected")
+)
+
+// Server debugs one machine per client session. Clock paces the running machine, realtime if nil.
+type Server struct {
+	Clock chip8.Clock
+
+	mu             sync.Mutex // guards the machine and breakpoints, running machine releases it between frames
+	chip           *chip8.Chip8
+	prog           *asm.Program // debug information when launched with assembler source
+	labels         map[uint16]string
+	stopOnEntry    bool
+	srcBreakpoints map[string][]uint16 // by source path
+	insBreakpoints []uint16
+	breakpoints    map[uint16]bool // all of the above
+	run            *runState
+
+	outMu sync.Mutex
+	out   io.Writer
+	seq   int
+}
+
+// runState is the running machine goroutine, it is cancelled with errPause or errDisconnect cause
+type runState struct {
+	cancel context.CancelCauseFunc
+	done   chan struct{}
+}
+
+// ListenAndServe accepts DAP clients on TCP address (i.e. localhost:4711) and serves them one by one
+func (s *Server) ListenAndServe(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		err = s.Serve(conn, conn)
+		conn.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Serve handles client session until disconnect request or end of input
+func (s *Server) Serve(in io.Reader, out io.Writer) error {
+	s.mu.Lock()
+	s.chip, s.prog, s.labels = nil, nil, map[uint16]string{}
+	s.srcBreakpoints, s.insBreakpoints, s.breakpoints = map[string][]uint16{}, nil, map[uint16]bool{}
+	s.mu.Unlock()
+	s.out, s.seq = out, 0
+
+	if s.Clock == nil {
+		clock := chip8.NewRealtimeClock()
+		defer func() {
+			clock.Stop()
+			s.Clock = nil
+		}()
+		s.Clock = clock
+	}
+	defer s.halt(errDisconnect)
+
+	reader := bufio.NewReader(in)
+	for {
+		data, err := readMessage(reader)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(data, &req); err != nil {
+			return fmt.Errorf("invalid message: %w", err)
+		}
+		if req.Type != "request" {
+			continue
+		}
+
+		body, after, err := s.handle(&req)
+		resp := response{Type: "response", RequestSeq: req.Seq, Success: err == nil, Command: req.Command, Body: body}
+		if err != nil {
+			resp.Message = err.Error()
+		}
+		if err := s.send(&resp); err != nil {
+			return err
+		}
+
+		if after != nil {
+			after()
+		}
+		if req.Command == "disconnect" {
+			return nil
+		}
+	}
+}
+
+// send writes response or event with the next sequence number
+func (s *Server) send(msg any) error {
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+
+	s.seq++
+	switch m := msg.(type) {
+	case *response:
+		m.Seq = s.seq
+	case *event:
+		m.Seq = s.seq
+	}
+
+	return writeMessage(s.out, msg)
+}
+
+func (s *Server) sendEvent(name string, body any) {
+	// write errors end the session on the next read
+	s.send(&event{Type: "event", Event: name, Body: body})
+}
+
+// handle executes request, returns response body and optional function called after the response is sent
+func (s *Server) handle(req *request) (any, func(), error) {
+	decode := func(args any) error {
+		if len(req.Arguments) == 0 {
+			return nil
+		}
+		return json.Unmarshal(req.Arguments, args)
+	}
+
+	if req.Command == "initialize" {
+		body := capabilities{SupportsConfigurationDoneRequest: true, SupportsInstructionBreakpoints: true, SupportsTerminateRequest: true}
+		return body, func() { s.sendEvent("initialized", nil) }, nil
+	}
+	if req.Command == "launch" {
+		var args launchArguments
+		if err := decode(&args); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, s.launch(&args)
+	}
+	if req.Command == "disconnect" || req.Command == "terminate" {
+		s.halt(errDisconnect)
+		if req.Command == "terminate" {
+			return nil, func() { s.sendEvent("terminated", nil) }, nil
+		}
+		return nil, nil, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.chip == nil {
+		return nil, nil, fmt.Errorf("%s: program is not launched", req.Command)
+	}
+
+	switch req.Command {
+	case "setBreakpoints":
+		var args setBreakpointsArguments
+		if err := decode(&args); err != nil {
+			return nil, nil, err
+		}
+		return s.setBreakpoints(&args), nil, nil
+	case "setInstructionBreakpoints":
+		var args setInstructionBreakpointsArguments
+		if err := decode(&args); err != nil {
+			return nil, nil, err
+		}
+		return s.setInstructionBreakpoints(&args), nil, nil
+	case "setExceptionBreakpoints":
+		return breakpointsBody{Breakpoints: []breakpoint{}}, nil, nil
+	case "configurationDone":
+		if s.stopOnEntry {
+			return nil, func() {
+				s.sendEvent("stopped", stoppedBody{Reason: "entry", ThreadID: THREAD_ID, AllThreadsStopped: true})
+			}, nil
+		}
+		// breakpoint at the entry is hit before the first instruction runs
+		if s.breakpoints[s.chip.Reg.PC] {
+			return nil, func() {
+				s.sendEvent("stopped", stoppedBody{Reason: "breakpoint", ThreadID: THREAD_ID, AllThreadsStopped: true})
+			}, nil
+		}
+		s.start(func() bool { return false })
+	case "threads":
+		return threadsBody{Threads: []thread{{ID: THREAD_ID, Name: "CHIP-8"}}}, nil, nil
+	case "stackTrace":
+		frames := s.stackTrace()
+		return stackTraceBody{StackFrames: frames, TotalFrames: len(frames)}, nil, nil
+	case "scopes":
+		return scopesBody{Scopes: []scope{{Name: "Registers", VariablesReference: REGISTERS_REF}}}, nil, nil
+	case "variables":
+		var args variablesArguments
+		if err := decode(&args); err != nil {
+			return nil, nil, err
+		}
+		if args.VariablesReference != REGISTERS_REF {
+			return variablesBody{Variables: []variable{}}, nil, nil
+		}
+		return variablesBody{Variables: s.registers()}, nil, nil
+	case "continue":
+		s.start(func() bool { return false })
+		return continuedBody{ThreadID: THREAD_ID, AllThreadsContinued: true}, nil, nil
+	case "next":
+		s.start(s.stepOver())
+	case "stepIn":
+		steps := 0
+		s.start(func() bool { steps++; return steps >= 1 })
+	case "stepOut":
+		sp := s.chip.Reg.SP
+		// stack grows down, so returned from the current subroutine when SP is above the current one
+		s.start(func() bool { return s.chip.Reg.SP > sp })
+	case "pause":
+		if s.run != nil {
+			s.run.cancel(errPause)
+		}
+	case "evaluate":
+		var args evaluateArguments
+		if err := decode(&args); err != nil {
+			return nil, nil, err
+		}
+		result, err := s.evaluate(&args)
+		if err != nil {
+			return nil, nil, err
+		}
+		return evaluateBody{Result: result}, nil, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported command %q", req.Command)
+	}
+
+	return nil, nil, nil
+}
+
+func (s *Server) launch(args *launchArguments) error {
+	s.halt(errDisconnect)
+
+	ver := chip8.Chip_8
+	if args.Platform != "" {
+		var err error
+		if ver, err = chip8.ParseChipVersion(args.Platform); err != nil {
+			return err
+		}
+	}
+
+	program, err := filepath.Abs(args.Program)
+	if err != nil {
+		return err
+	}
+
+	chip := &chip8.Chip8{}
+	chip.Init(ver)
+	// errors pause the machine, the server reports them as exceptions
+	chip.OnError = chip8.PolicyTrap
+
+	var prog *asm.Program
+	if strings.EqualFold(filepath.Ext(program), ".asm") {
+		assembler := asm.Assembler{}
+		if prog, err = assembler.BuildFile(program); err != nil {
+			return err
+		}
+		if len(prog.ROM) > chip.MemorySize()-int(chip8.MEMORY_USER) {
+			return chip8.ErrMemoryOutOfBounds
+		}
+		chip.LoadRomFromData(prog.ROM)
+	} else if _, err := chip.LoadRomFromFile(program); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.chip, s.prog, s.stopOnEntry = chip, prog, args.StopOnEntry
+	s.labels = map[uint16]string{}
+	if prog != nil {
+		for name, adr := range prog.Labels {
+			s.labels[adr] = name
+		}
+	}
+
+	return nil
+}
+
+// start runs the machine until stop condition is met after an instruction, breakpoint, error or pause
+func (s *Server) start(stop func() bool) {
+	if s.run != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	s.run = &runState{cancel: cancel, done: make(chan struct{})}
+	go s.runLoop(ctx, s.run, stop)
+}
+
+// halt stops the running machine with the cause and waits for it
+func (s *Server) halt(cause error) {
+	s.mu.Lock()
+	run := s.run
+	s.mu.Unlock()
+
+	if run != nil {
+		run.cancel(cause)
+		<-run.done
+	}
+}
+
+func (s *Server) runLoop(ctx context.Context, run *runState, stop func() bool) {
+	defer close(run.done)
+
+	for {
+		s.mu.Lock()
+		body, terminated := s.runFrame(ctx, stop)
+		if body != nil || terminated || ctx.Err() != nil {
+			s.run = nil
+		}
+		s.mu.Unlock()
+
+		if terminated {
+			s.sendEvent("exited", exitedBody{ExitCode: 0})
+			s.sendEvent("terminated", nil)
+			return
+		}
+		if body != nil {
+			s.sendEvent("stopped", body)
+			return
+		}
+
+		if ctx.Err() == nil {
+			s.Clock.Wait(ctx)
+		}
+		if ctx.Err() != nil {
+			s.mu.Lock()
+			s.run = nil
+			s.mu.Unlock()
+
+			if context.Cause(ctx) == errPause {
+				s.sendEvent("stopped", stoppedBody{Reason: "pause", ThreadID: THREAD_ID, AllThreadsStopped: true})
+			}
+			return
+		}
+	}
+}
+
+// runFrame executes the rest of the frame, returns stopped event body or true if the machine is stopped
+func (s *Server) runFrame(ctx context.Context, stop func() bool) (*stoppedBody, bool) {
+	chip := s.chip
+
+	stopped := func(reason, description string) *stoppedBody {
+		return &stoppedBody{Reason: reason, Description: description, Text: description, ThreadID: THREAD_ID, AllThreadsStopped: true}
+	}
+
+	for ctx.Err() == nil {
+		if !chip.State.Running {
+			return nil, true
+		}
+
+		frameEnd, err := chip.FrameStep()
+		if err != nil {
+			// the client resumes the machine by its controls, so the trap pause is dropped
+			chip.State.Paused = false
+			return stopped("exception", err.Error()), false
+		}
+		if chip.State.Paused && chip.State.Watch != nil {
+			chip.State.Paused = false
+			return stopped("data breakpoint", chip.State.Watch.String()), false
+		}
+		if !chip.State.Running {
+			return nil, true
+		}
+		if stop() {
+			return stopped("step", ""), false
+		}
+		if s.breakpoints[chip.Reg.PC] {
+			return stopped("breakpoint", ""), false
+		}
+		if frameEnd {
+			break
+		}
+	}
+
+	return nil, false
+}
+
+// stepOver steps over subroutine call, otherwise executes single instruction
+func (s *Server) stepOver() func() bool {
+	chip := s.chip
+
+	in := chip8.DecodeAt(chip.Memory[:chip.MemorySize()], int(chip.Reg.PC))
+	if in.Op == chip8.OpCall {
+		sp := chip.Reg.SP
+		return func() bool { return chip.Reg.SP >= sp }
+	}
+
+	steps := 0
+	return func() bool { steps++; return steps >= 1 }
+}
+
+func (s *Server) setBreakpoints(args *setBreakpointsArguments) breakpointsBody {
+	path := filepath.Clean(args.Source.Path)
+
+	var adrs []uint16
+	body := breakpointsBody{Breakpoints: []breakpoint{}}
+	for _, sbp := range args.Breakpoints {
+		bp := breakpoint{Source: &args.Source, Line: sbp.Line}
+		if s.prog == nil {
+			bp.Message = "no line information, program is not launched from assembler source"
+		} else if adr, line, found := s.prog.Address(path, sbp.Line); found {
+			bp.Verified, bp.Line = true, line
+			bp.InstructionReference = fmt.Sprintf("0x%04x", adr)
+			adrs = append(adrs, adr)
+		} else {
+			bp.Message = "no code at or after the line"
+		}
+		body.Breakpoints = append(body.Breakpoints, bp)
+	}
+
+	s.srcBreakpoints[path] = adrs
+	s.updateBreakpoints()
+
+	return body
+}
+
+func (s *Server) setInstructionBreakpoints(args *setInstructionBreakpointsArguments) breakpointsBody {
+	s.insBreakpoints = nil
+	body := breakpointsBody{Breakpoints: []breakpoint{}}
+	for _, ibp := range args.Breakpoints {
+		bp := breakpoint{InstructionReference: ibp.InstructionReference}
+
+		adr, err := strconv.ParseUint(ibp.InstructionReference, 0, 16)
+		if err == nil && int(adr)+ibp.Offset >= 0 && int(adr)+ibp.Offset < s.chip.MemorySize() {
+			adr := uint16(int(adr) + ibp.Offset)
+			bp.Verified = true
+			bp.InstructionReference = fmt.Sprintf("0x%04x", adr)
+			if pos, ok := s.position(adr); ok {
+				bp.Source, bp.Line = pos, s.prog.Positions[adr].Line
+			}
+			s.insBreakpoints = append(s.insBreakpoints, adr)
+		} else {
+			bp.Message = "invalid address"
+		}
+		body.Breakpoints = append(body.Breakpoints, bp)
+	}
+
+	s.updateBreakpoints()
+	return body
+}
+
+func (s *Server) updateBreakpoints() {
+	s.breakpoints = map[uint16]bool{}
+	for _, adrs := range s.srcBreakpoints {
+		for _, adr := range adrs {
+			s.breakpoints[adr] = true
+		}
+	}
+	for _, adr := range s.insBreakpoints {
+		s.breakpoints[adr] = true
+	}
+}
+
+// position returns the source of the address if the program has line information
+func (s *Server) position(adr uint16) (*source, bool) {
+	if s.prog == nil {
+		return nil, false
+	}
+	pos, ok := s.prog.Positions[adr]
+	if !ok {
+		return nil, false
+	}
+	return &source{Name: filepath.Base(pos.File), Path: pos.File}, true
+}
+
+func (s *Server) stackTrace() []stackFrame {
+	chip := s.chip
+
+	var frames []stackFrame
+	addFrame := func(name string, pc uint16) {
+		frame := stackFrame{ID: len(frames) + 1, Name: fmt.Sprintf("%s @ %04x", name, pc), InstructionPointerReference: fmt.Sprintf("0x%04x", pc)}
+		if src, ok := s.position(pc); ok {
+			frame.Source, frame.Line, frame.Column = src, s.prog.Positions[pc].Line, 1
+		}
+		frames = append(frames, frame)
+	}
+
+	// PC belongs to the innermost subroutine, CALL instructions to the outer ones
+	pc := chip.Reg.PC
+	for _, call := range chip.CallStack() {
+		name := fmt.Sprintf("sub_%04x", call.Entry)
+		if label, ok := s.labels[call.Entry]; ok {
+			name = label
+		}
+		addFrame(name, pc)
+		pc = call.Caller
+	}
+	addFrame("main", pc)
+
+	return frames
+}
+
+// replCommands are the terminal debugger commands allowed in REPL, the ones running the machine
+// would block the session and move PC without stopped event
+var replCommands = map[string]bool{
+	"regs": true, "r": true, "bt": true, "mem": true, "m": true, "screen": true, "dis": true,
+	"set": true, "key": true, "help": true, "h": true,
+}
+
+var registerNames = []string{
+	"V0", "V1", "V2", "V3", "V4", "V5", "V6", "V7", "V8", "V9", "VA", "VB", "VC", "VD", "VE", "VF",
+	"I", "PC", "SP", "T0", "T1",
+}
+
+// register returns register value formatted as the debugger prints it
+func (s *Server) register(name string) (string, bool) {
+	reg := s.chip.Reg
+	switch name = strings.ToUpper(name); name {
+	case "I":
+		return fmt.Sprintf("0x%04x", reg.I), true
+	case "PC":
+		return fmt.Sprintf("0x%04x", reg.PC), true
+	case "SP":
+		return fmt.Sprintf("0x%04x", reg.SP), true
+	case "T0":
+		return fmt.Sprintf("0x%02x", reg.T0), true
+	case "T1":
+		return fmt.Sprintf("0x%02x", reg.T1), true
+	}
+
+	if len(name) == 2 && name[0] == 'V' {
+		if r, err := strconv.ParseUint(name[1:], 16, 4); err == nil {
+			return fmt.Sprintf("0x%02x", reg.V[r]), true
+		}
+	}
+	return "", false
+}
+
+func (s *Server) registers() []variable {
+	vars := make([]variable, 0, len(registerNames))
+	for _, name := range registerNames {
+		value, _ := s.register(name)
+		vars = append(vars, variable{Name: name, Value: value})
+	}
+	return vars
+}
+
+// evaluate returns register value or executes the terminal debugger command in REPL
+func (s *Server) evaluate(args *evaluateArguments) (string, error) {
+	expr := strings.TrimSpace(args.Expression)
+	if value, ok := s.register(expr); ok {
+		return value, nil
+	}
+	if args.Context != "repl" {
+		return "", fmt.Errorf("unknown register %q", expr)
+	}
+
+	if cmd, _, _ := strings.Cut(expr, " "); !replCommands[strings.ToLower(cmd)] {
+		return "", fmt.Errorf("%q is not allowed in debug console, use the debugger controls to run the program", cmd)
+	}
+
+	var out strings.Builder
+	if _, err := debugger.New(s.chip, &out).Exec(expr); err != nil {
+		return "", err
+	}
+	return strings.TrimRight(out.String(), "\n"), nil
+}
@@ -0,0 +1,320 @@
+package dap_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brus-fabrika/chip8/chip8"
+	"github.com/brus-fabrika/chip8/dap"
+)
+
+const testSource = `start:	MOV V1, 00
+loop:	CALL sub
+	JMP loop
+sub:	ADD V1, 01
+	ADD V1, 01
+	RET
+`
+
+type message map[string]any
+
+// client is a scripted DAP client talking to the server through pipes
+type client struct {
+	t      *testing.T
+	w      io.Writer
+	msgs   chan message
+	events []message
+	seq    int
+	done   chan error
+}
+
+func newClient(t *testing.T) *client {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+
+	c := &client{t: t, w: clientOut, msgs: make(chan message, 100), done: make(chan error, 1)}
+
+	server := &dap.Server{Clock: chip8.FreeClock{}}
+	go func() {
+		c.done <- server.Serve(serverIn, serverOut)
+		serverOut.Close()
+	}()
+
+	go func() {
+		defer close(c.msgs)
+		r := bufio.NewReader(clientIn)
+		for {
+			var length int
+			header, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			fmt.Sscanf(header, "Content-Length: %d", &length)
+			r.ReadString('\n')
+
+			body := make([]byte, length)
+			if _, err := io.ReadFull(r, body); err != nil {
+				return
+			}
+			var msg message
+			json.Unmarshal(body, &msg)
+			c.msgs <- msg
+		}
+	}()
+
+	return c
+}
+
+func (c *client) next() message {
+	select {
+	case msg, ok := <-c.msgs:
+		require.True(c.t, ok, "server closed connection")
+		return msg
+	case <-time.After(5 * time.Second):
+		require.FailNow(c.t, "no message from server")
+		return nil
+	}
+}
+
+// request sends the command and waits for its response, events are queued
+func (c *client) request(command string, args any) message {
+	c.seq++
+	data, _ := json.Marshal(message{"seq": c.seq, "type": "request", "command": command, "arguments": args})
+	fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n%s", len(data), data)
+
+	for {
+		msg := c.next()
+		if msg["type"] == "response" && msg["request_seq"] == float64(c.seq) {
+			assert.Equal(c.t, command, msg["command"])
+			return msg
+		}
+		c.events = append(c.events, msg)
+	}
+}
+
+// event waits for the event, earlier events are skipped
+func (c *client) event(name string) message {
+	for {
+		var msg message
+		if len(c.events) > 0 {
+			msg, c.events = c.events[0], c.events[1:]
+		} else {
+			msg = c.next()
+		}
+		if msg["type"] == "event" && msg["event"] == name {
+			return msg
+		}
+	}
+}
+
+func (c *client) stopped(reason string) message {
+	ev := c.event("stopped")
+	assert.Equal(c.t, reason, ev["body"].(map[string]any)["reason"])
+	return ev
+}
+
+func (c *client) eval(expr string) string {
+	resp := c.request("evaluate", message{"expression": expr, "context": "repl"})
+	require.Equal(c.t, true, resp["success"], resp["message"])
+	return resp["body"].(map[string]any)["result"].(string)
+}
+
+func body(resp message) map[string]any {
+	return resp["body"].(map[string]any)
+}
+
+func TestBreakpointsAndStepping(t *testing.T) {
+	dir := t.TempDir()
+	program := filepath.Join(dir, "test.asm")
+	require.NoError(t, os.WriteFile(program, []byte(testSource), 0o644))
+
+	c := newClient(t)
+	c.request("initialize", message{"adapterID": "chip8"})
+	c.event("initialized")
+	resp := c.request("launch", message{"program": program, "stopOnEntry": true})
+	require.Equal(t, true, resp["success"], resp["message"])
+
+	resp = c.request("setBreakpoints", message{
+		"source":      message{"path": program},
+		"breakpoints": []message{{"line": 5}, {"line": 7}},
+	})
+	bps := body(resp)["breakpoints"].([]any)
+	if assert.Len(t, bps, 2) {
+		assert.Equal(t, true, bps[0].(map[string]any)["verified"])
+		assert.Equal(t, "0x0208", bps[0].(map[string]any)["instructionReference"])
+		assert.Equal(t, false, bps[1].(map[string]any)["verified"])
+	}
+
+	c.request("configurationDone", nil)
+	c.stopped("entry")
+
+	c.request("continue", message{"threadId": 1})
+	c.stopped("breakpoint")
+
+	resp = c.request("stackTrace", message{"threadId": 1})
+	frames := body(resp)["stackFrames"].([]any)
+	if assert.Len(t, frames, 2) {
+		top, caller := frames[0].(map[string]any), frames[1].(map[string]any)
+		assert.Equal(t, "sub @ 0208", top["name"])
+		assert.Equal(t, float64(5), top["line"])
+		assert.Equal(t, program, top["source"].(map[string]any)["path"])
+		assert.Equal(t, "main @ 0202", caller["name"])
+		assert.Equal(t, float64(2), caller["line"])
+	}
+
+	resp = c.request("scopes", message{"frameId": 1})
+	ref := body(resp)["scopes"].([]any)[0].(map[string]any)["variablesReference"]
+	resp = c.request("variables", message{"variablesReference": ref})
+	vars := body(resp)["variables"].([]any)
+	if assert.Len(t, vars, 21) {
+		assert.Equal(t, map[string]any{"name": "V1", "value": "0x01", "variablesReference": float64(0)}, vars[1])
+		assert.Equal(t, "PC", vars[17].(map[string]any)["name"])
+		assert.Equal(t, "0x0208", vars[17].(map[string]any)["value"])
+	}
+
+	c.request("stepOut", message{"threadId": 1})
+	c.stopped("step")
+	assert.Equal(t, "0x0204", c.eval("PC"))
+	assert.Equal(t, "0x02", c.eval("v1"))
+
+	// JMP loop, then CALL sub is stepped over until the breakpoint inside
+	c.request("next", message{"threadId": 1})
+	c.stopped("step")
+	assert.Equal(t, "0x0202", c.eval("PC"))
+	c.request("next", message{"threadId": 1})
+	c.stopped("breakpoint")
+	assert.Equal(t, "0x0208", c.eval("PC"))
+
+	c.request("stepIn", message{"threadId": 1})
+	c.stopped("step")
+	assert.Equal(t, "0x020a", c.eval("PC"))
+
+	// by address
+	c.request("setBreakpoints", message{"source": message{"path": program}, "breakpoints": []message{}})
+	resp = c.request("setInstructionBreakpoints", message{"breakpoints": []message{{"instructionReference": "0x0206"}}})
+	bps = body(resp)["breakpoints"].([]any)
+	if assert.Len(t, bps, 1) {
+		assert.Equal(t, true, bps[0].(map[string]any)["verified"])
+		assert.Equal(t, float64(4), bps[0].(map[string]any)["line"])
+	}
+	c.request("continue", message{"threadId": 1})
+	c.stopped("breakpoint")
+	assert.Equal(t, "0x0206", c.eval("PC"))
+
+	// debugger commands in REPL
+	c.eval("set V1 42")
+	assert.Equal(t, "0x42", c.eval("V1"))
+	assert.Contains(t, c.eval("dis 0206 1"), "ADD V1, 01")
+
+	resp = c.request("disconnect", nil)
+	assert.Equal(t, true, resp["success"])
+	assert.NoError(t, <-c.done)
+}
+
+func TestBreakpointAtEntry(t *testing.T) {
+	dir := t.TempDir()
+	program := filepath.Join(dir, "test.asm")
+	require.NoError(t, os.WriteFile(program, []byte(testSource), 0o644))
+
+	c := newClient(t)
+	c.request("initialize", nil)
+	resp := c.request("launch", message{"program": program})
+	require.Equal(t, true, resp["success"], resp["message"])
+	c.request("setBreakpoints", message{
+		"source":      message{"path": program},
+		"breakpoints": []message{{"line": 1}, {"line": 4}},
+	})
+
+	// the first instruction is not executed before its breakpoint
+	c.request("configurationDone", nil)
+	c.stopped("breakpoint")
+	assert.Equal(t, "0x0200", c.eval("PC"))
+
+	// resumed from the entry breakpoint, the next one is hit
+	c.request("continue", message{"threadId": 1})
+	c.stopped("breakpoint")
+	assert.Equal(t, "0x0206", c.eval("PC"))
+
+	c.request("disconnect", nil)
+	assert.NoError(t, <-c.done)
+}
+
+func TestPauseAndErrors(t *testing.T) {
+	dir := t.TempDir()
+	// MOV V1, 00; JMP 0x0202
+	program := filepath.Join(dir, "loop.ch8")
+	require.NoError(t, os.WriteFile(program, []uint8{0x61, 0x00, 0x12, 0x02}, 0o644))
+
+	c := newClient(t)
+	c.request("initialize", nil)
+	resp := c.request("launch", message{"program": program})
+	require.Equal(t, true, resp["success"], resp["message"])
+
+	// no line information for ROMs
+	resp = c.request("setBreakpoints", message{"source": message{"path": program}, "breakpoints": []message{{"line": 1}}})
+	assert.Equal(t, false, body(resp)["breakpoints"].([]any)[0].(map[string]any)["verified"])
+
+	c.request("configurationDone", nil)
+	c.request("pause", message{"threadId": 1})
+	c.stopped("pause")
+	assert.Equal(t, "0x0202", c.eval("PC"))
+
+	resp = c.request("stackTrace", message{"threadId": 1})
+	frames := body(resp)["stackFrames"].([]any)
+	if assert.Len(t, frames, 1) {
+		assert.Equal(t, "main @ 0202", frames[0].(map[string]any)["name"])
+		assert.Equal(t, "0x0202", frames[0].(map[string]any)["instructionPointerReference"])
+	}
+
+	// invalid instruction
+	c.eval("set mem 0202 ff ff")
+	c.request("continue", message{"threadId": 1})
+	ev := c.stopped("exception")
+	assert.Contains(t, body(ev)["description"], "invalid opcode")
+
+	resp = c.request("foo", nil)
+	assert.Equal(t, false, resp["success"])
+
+	// running commands in REPL are rejected, the session keeps handling requests
+	for _, expr := range []string{"c", "continue", "step 10", "finish", "quit"} {
+		resp = c.request("evaluate", message{"expression": expr, "context": "repl"})
+		assert.Equal(t, false, resp["success"], expr)
+	}
+	assert.Equal(t, "0x0202", c.eval("PC"))
+
+	resp = c.request("launch", message{"program": filepath.Join(dir, "missing.ch8")})
+	assert.Equal(t, false, resp["success"])
+
+	c.request("terminate", nil)
+	c.event("terminated")
+	c.request("disconnect", nil)
+	assert.NoError(t, <-c.done)
+}
+
+func TestExit(t *testing.T) {
+	dir := t.TempDir()
+	program := filepath.Join(dir, "exit.asm")
+	require.NoError(t, os.WriteFile(program, []byte("MOV V1, 01\nEXIT\n"), 0o644))
+
+	c := newClient(t)
+	c.request("initialize", nil)
+	resp := c.request("launch", message{"program": program, "platform": "schip"})
+	require.Equal(t, true, resp["success"], resp["message"])
+	c.request("configurationDone", nil)
+	c.event("exited")
+	c.event("terminated")
+
+	assert.True(t, strings.HasPrefix(c.eval("V1"), "0x01"))
+	c.request("disconnect", nil)
+	assert.NoError(t, <-c.done)
+}
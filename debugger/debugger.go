@@ -0,0 +1,359 @@
+// Package debugger is an interactive terminal debugger for the CHIP-8 machine
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/brus-fabrika/chip8/chip8"
+)
+
+const HELP = `Commands (numbers are hex):
+  break [adr]           set breakpoint at address, list breakpoints without address
+  delete adr            remove breakpoint
+  watch [acc:from[-to]] set memory watchpoint, acc is r, w, x or their mix, list watchpoints without argument
+  unwatch acc:from[-to] remove watchpoint
+  step [n], s           execute n instructions (1 by default)
+  continue, c           run until breakpoint, watchpoint, error or key wait
+  finish                run until return from the current subroutine
+  regs, r               print registers
+  bt                    print call stack, the innermost frame first
+  mem start [end], m    print memory
+  screen                print display
+  dis [adr] [n]         disassemble n instructions from address (PC by default)
+  set reg value         set register: V0-VF, I, PC, SP, T0, T1
+  set mem adr byte...   set memory bytes
+  key k [0]             press (or release with 0) keypad key k
+  help, h               this help
+  quit, q               exit
+`
+
+// Debugger controls the machine by text commands, instructions are stepped with the frame timing of RunFrame
+type Debugger struct {
+	Chip *chip8.Chip8
+	Out  io.Writer
+
+	breakpoints map[uint16]bool
+	interrupted atomic.Bool
+}
+
+func New(chip *chip8.Chip8, out io.Writer) *Debugger {
+	return &Debugger{Chip: chip, Out: out, breakpoints: map[uint16]bool{}}
+}
+
+// Run reads and executes commands until quit or end of input
+func (d *Debugger) Run(in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+
+	d.printCurrent()
+	fmt.Fprint(d.Out, "> ")
+	for scanner.Scan() {
+		quit, err := d.Exec(scanner.Text())
+		if err != nil {
+			fmt.Fprintln(d.Out, "error:", err)
+		}
+		if quit {
+			return nil
+		}
+		fmt.Fprint(d.Out, "> ")
+	}
+
+	return scanner.Err()
+}
+
+// Interrupt stops running continue or finish command, safe to call from other goroutine (i.e. on Ctrl-C)
+func (d *Debugger) Interrupt() {
+	d.interrupted.Store(true)
+}
+
+// Exec executes single command line, returns true on quit
+func (d *Debugger) Exec(line string) (bool, error) {
+	args := strings.Fields(line)
+	if len(args) == 0 {
+		return false, nil
+	}
+
+	cmd, args := strings.ToLower(args[0]), args[1:]
+	switch cmd {
+	case "break", "b":
+		if len(args) == 0 {
+			d.listBreakpoints()
+			return false, nil
+		}
+		adr, err := parseHex(args[0], 0xffff)
+		if err != nil {
+			return false, err
+		}
+		d.breakpoints[uint16(adr)] = true
+	case "delete", "d":
+		if len(args) != 1 {
+			return false, fmt.Errorf("delete expects address")
+		}
+		adr, err := parseHex(args[0], 0xffff)
+		if err != nil {
+			return false, err
+		}
+		delete(d.breakpoints, uint16(adr))
+	case "watch":
+		if len(args) == 0 {
+			for _, wp := range d.Chip.Watchpoints {
+				fmt.Fprintln(d.Out, wp)
+			}
+			return false, nil
+		}
+		wp, err := chip8.ParseWatchpoint(args[0])
+		if err != nil {
+			return false, err
+		}
+		if !slices.Contains(d.Chip.Watchpoints, wp) {
+			d.Chip.Watchpoints = append(d.Chip.Watchpoints, wp)
+		}
+	case "unwatch":
+		if len(args) != 1 {
+			return false, fmt.Errorf("unwatch expects watchpoint")
+		}
+		wp, err := chip8.ParseWatchpoint(args[0])
+		if err != nil {
+			return false, err
+		}
+		d.Chip.Watchpoints = slices.DeleteFunc(d.Chip.Watchpoints, func(w chip8.Watchpoint) bool { return w == wp })
+	case "step", "s":
+		n := 1
+		if len(args) > 0 {
+			val, err := parseHex(args[0], 0xffff)
+			if err != nil {
+				return false, err
+			}
+			n = val
+		}
+		d.run(n, func() bool { return false })
+	case "continue", "c":
+		d.run(-1, func() bool { return d.breakpoints[d.Chip.Reg.PC] })
+	case "finish":
+		sp := d.Chip.Reg.SP
+		// stack grows down, so returned from the current subroutine when SP is above the current one
+		d.run(-1, func() bool { return d.Chip.Reg.SP > sp || d.breakpoints[d.Chip.Reg.PC] })
+	case "regs", "r":
+		d.Chip.RegistryDumpTo(d.Out)
+	case "bt":
+		d.backtrace()
+	case "mem", "m":
+		if len(args) == 0 {
+			return false, fmt.Errorf("mem expects start address")
+		}
+		start, err := parseHex(args[0], 0xffff)
+		if err != nil {
+			return false, err
+		}
+		end := start + 0x3f
+		if len(args) > 1 {
+			if end, err = parseHex(args[1], 0xffff); err != nil {
+				return false, err
+			}
+		}
+		d.Chip.MemoryDumpTo(d.Out, uint16(start), uint16(min(end, 0xffef)))
+	case "screen":
+		d.Chip.DisplayDumpTo(d.Out)
+	case "dis":
+		return false, d.disassemble(args)
+	case "set":
+		return false, d.set(args)
+	case "key":
+		if len(args) == 0 {
+			return false, fmt.Errorf("key expects keypad key")
+		}
+		key, err := parseHex(args[0], 0xf)
+		if err != nil {
+			return false, err
+		}
+		d.Chip.Keyboard[key] = len(args) < 2 || args[1] != "0"
+	case "help", "h":
+		fmt.Fprint(d.Out, HELP)
+	case "quit", "q":
+		return true, nil
+	default:
+		return false, fmt.Errorf("unknown command %q, try help", cmd)
+	}
+
+	return false, nil
+}
+
+// run executes up to n instructions (unlimited for n < 0) until stop condition is met after an instruction,
+// machine stops, pauses on error or waits for a key
+func (d *Debugger) run(n int, stop func() bool) {
+	chip := d.Chip
+	d.interrupted.Store(false)
+
+	for i := 0; n < 0 || i < n; i++ {
+		if !chip.State.Running {
+			fmt.Fprintln(d.Out, "machine is stopped")
+			break
+		}
+
+		_, err := chip.FrameStep()
+		if err != nil {
+			fmt.Fprintln(d.Out, "error:", err)
+			// debugger takes care of the machine from now, trap pause is not needed
+			chip.State.Paused = false
+			break
+		}
+		if chip.State.Paused && chip.State.Watch != nil {
+			fmt.Fprintln(d.Out, chip.State.Watch)
+			chip.State.Paused = false
+			break
+		}
+		if chip.State.WaitingForKey {
+			fmt.Fprintln(d.Out, "waiting for key")
+			break
+		}
+		if stop() || d.interrupted.Load() {
+			break
+		}
+	}
+
+	d.printCurrent()
+}
+
+// backtrace prints frames as "#n address in subroutine", PC belongs to the innermost subroutine
+func (d *Debugger) backtrace() {
+	pc := d.Chip.Reg.PC
+	for i, frame := range d.Chip.CallStack() {
+		fmt.Fprintf(d.Out, "#%d %04x in sub_%04x\n", i, pc, frame.Entry)
+		pc = frame.Caller
+	}
+	fmt.Fprintf(d.Out, "#%d %04x in main (depth %d of %d)\n", d.Chip.StackDepth(), pc, d.Chip.StackDepth(), d.Chip.StackLimit())
+}
+
+// printCurrent prints the instruction at PC
+func (d *Debugger) printCurrent() {
+	d.printInstruction(d.Chip.Reg.PC)
+}
+
+func (d *Debugger) printInstruction(adr uint16) uint16 {
+	mem := d.Chip.Memory[:d.Chip.MemorySize()]
+	in := chip8.DecodeAt(mem, int(adr))
+
+	marker := " "
+	if d.breakpoints[adr] {
+		marker = "*"
+	}
+	fmt.Fprintf(d.Out, "%s%04x: %04x\t%s\n", marker, adr, in.Opcode, in)
+
+	return in.Size()
+}
+
+func (d *Debugger) listBreakpoints() {
+	adrs := make([]int, 0, len(d.breakpoints))
+	for adr := range d.breakpoints {
+		adrs = append(adrs, int(adr))
+	}
+	sort.Ints(adrs)
+
+	for _, adr := range adrs {
+		d.printInstruction(uint16(adr))
+	}
+}
+
+func (d *Debugger) disassemble(args []string) error {
+	adr, n := int(d.Chip.Reg.PC), 10
+
+	var err error
+	if len(args) > 0 {
+		if adr, err = parseHex(args[0], 0xffff); err != nil {
+			return err
+		}
+	}
+	if len(args) > 1 {
+		if n, err = parseHex(args[1], 0xffff); err != nil {
+			return err
+		}
+	}
+
+	for i := 0; i < n && adr < d.Chip.MemorySize(); i++ {
+		adr += int(d.printInstruction(uint16(adr)))
+	}
+
+	return nil
+}
+
+func (d *Debugger) set(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("set expects register or memory and value")
+	}
+
+	chip := d.Chip
+	name := strings.ToUpper(args[0])
+
+	if name == "MEM" {
+		adr, err := parseHex(args[1], chip.MemorySize()-1)
+		if err != nil {
+			return err
+		}
+		for i, arg := range args[2:] {
+			val, err := parseHex(arg, 0xff)
+			if err != nil {
+				return err
+			}
+			if adr+i >= chip.MemorySize() {
+				return chip8.ErrMemoryOutOfBounds
+			}
+			chip.Memory[adr+i] = uint8(val)
+		}
+		return nil
+	}
+
+	switch {
+	case len(name) == 2 && name[0] == 'V':
+		reg, err := parseHex(name[1:], 0xf)
+		if err != nil {
+			return fmt.Errorf("unknown register %s", args[0])
+		}
+		val, err := parseHex(args[1], 0xff)
+		if err != nil {
+			return err
+		}
+		chip.Reg.V[reg] = uint8(val)
+	case name == "T0" || name == "T1":
+		val, err := parseHex(args[1], 0xff)
+		if err != nil {
+			return err
+		}
+		if name == "T0" {
+			chip.Reg.T0 = uint8(val)
+		} else {
+			chip.Reg.T1 = uint8(val)
+		}
+	case name == "I" || name == "PC" || name == "SP":
+		val, err := parseHex(args[1], 0xffff)
+		if err != nil {
+			return err
+		}
+		switch name {
+		case "I":
+			chip.Reg.I = uint16(val)
+		case "PC":
+			chip.Reg.PC = uint16(val)
+		case "SP":
+			chip.Reg.SP = uint16(val)
+		}
+	default:
+		return fmt.Errorf("unknown register %s", args[0])
+	}
+
+	return nil
+}
+
+// parseHex parses hex value (with or without 0x) in 0-limit range
+func parseHex(s string, limit int) (int, error) {
+	val, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(s), "0x"), 16, 32)
+	if err != nil || int(val) > limit {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	return int(val), nil
+}
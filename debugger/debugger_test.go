@@ -0,0 +1,176 @@
+package debugger_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brus-fabrika/chip8/chip8"
+	"github.com/brus-fabrika/chip8/debugger"
+)
+
+// MOV V1, 00; CALL 0x0208; JMP 0x0202; ADD V1, 01; ADD V1, 01; RET
+var testRom = []uint8{0x61, 0x00, 0x22, 0x08, 0x12, 0x02, 0x00, 0x00, 0x71, 0x01, 0x71, 0x01, 0x00, 0xee}
+
+func setup() (*chip8.Chip8, *debugger.Debugger, *strings.Builder) {
+	ch := &chip8.Chip8{}
+	ch.Init(chip8.Chip_8)
+	ch.OnError = chip8.PolicyTrap
+	ch.LoadRomFromData(testRom)
+
+	out := &strings.Builder{}
+	return ch, debugger.New(ch, out), out
+}
+
+func TestStepBreakContinue(t *testing.T) {
+	ch, d, out := setup()
+
+	d.Exec("step 2")
+	assert.Equal(t, uint16(0x0208), ch.Reg.PC)
+	assert.Contains(t, out.String(), "0208: 7101\tADD V1, 01")
+
+	d.Exec("break 020c")
+	d.Exec("c")
+	assert.Equal(t, uint16(0x020c), ch.Reg.PC)
+	assert.Equal(t, uint8(2), ch.Reg.V[1])
+
+	// breakpoint is hit on the next round
+	d.Exec("c")
+	assert.Equal(t, uint16(0x020c), ch.Reg.PC)
+	assert.Equal(t, uint8(4), ch.Reg.V[1])
+
+	d.Exec("delete 020c")
+	out.Reset()
+	d.Exec("break")
+	assert.Empty(t, out.String())
+}
+
+func TestStepFrameTiming(t *testing.T) {
+	ch, d, _ := setup()
+	// ADD V1, 01; DRAW 0, V1, 1; JMP 0x0200
+	ch.LoadRomFromData([]uint8{0x71, 0x01, 0xd0, 0x11, 0x12, 0x00})
+	ch.Reg.T0 = 10
+
+	// DRAW waits for vblank, so the frame is over and the timers tick
+	d.Exec("s 2")
+	assert.Equal(t, uint8(9), ch.Reg.T0)
+	assert.True(t, ch.State.WaitingForVBlank)
+
+	d.Exec("s")
+	assert.False(t, ch.State.WaitingForVBlank)
+	assert.Equal(t, uint8(9), ch.Reg.T0)
+
+	// two more frames end with DRAW
+	d.Exec("s 6")
+	assert.Equal(t, uint8(7), ch.Reg.T0)
+}
+
+func TestFinish(t *testing.T) {
+	ch, d, out := setup()
+
+	d.Exec("s 3")
+	assert.Equal(t, uint16(0x020a), ch.Reg.PC)
+
+	out.Reset()
+	d.Exec("bt")
+	assert.Equal(t, "#0 020a in sub_0208\n#1 0202 in main (depth 1 of 12)\n", out.String())
+
+	// limit off - the whole stack area
+	ch.MaxStackDepth = 0
+	out.Reset()
+	d.Exec("bt")
+	assert.Contains(t, out.String(), "(depth 1 of 24)")
+
+	d.Exec("finish")
+	assert.Equal(t, uint16(0x0204), ch.Reg.PC)
+	assert.Equal(t, uint8(2), ch.Reg.V[1])
+}
+
+func TestContinueStops(t *testing.T) {
+	ch, d, out := setup()
+
+	// invalid instruction
+	ch.Memory[0x0208] = 0xff
+	ch.Memory[0x0209] = 0xff
+	d.Exec("c")
+	assert.Equal(t, uint16(0x0208), ch.Reg.PC)
+	assert.Contains(t, out.String(), "invalid opcode")
+
+	// key wait
+	d.Exec("set mem 0208 f0 0a")
+	d.Exec("c")
+	assert.True(t, ch.State.WaitingForKey)
+	assert.Contains(t, out.String(), "waiting for key")
+
+	d.Exec("key 5")
+	d.Exec("s")
+	d.Exec("key 5 0")
+	d.Exec("s")
+	assert.Equal(t, uint8(5), ch.Reg.V[0])
+	assert.Equal(t, uint16(0x020a), ch.Reg.PC)
+}
+
+func TestWatch(t *testing.T) {
+	ch, d, out := setup()
+
+	_, err := d.Exec("watch w:0ea0-0ecf")
+	assert.NoError(t, err)
+	d.Exec("watch x:020a")
+
+	d.Exec("c")
+	assert.Equal(t, uint16(0x0208), ch.Reg.PC)
+	assert.False(t, ch.State.Paused)
+	assert.Contains(t, out.String(), "watchpoint w:0ea0-0ecf: w 0ece by 0202: 2208\tCALL 0x0208")
+
+	d.Exec("c")
+	assert.Equal(t, uint16(0x020c), ch.Reg.PC)
+	assert.Contains(t, out.String(), "watchpoint x:020a: x 020a by 020a")
+
+	d.Exec("unwatch w:0ea0-0ecf")
+	out.Reset()
+	d.Exec("watch")
+	assert.Equal(t, "x:020a\n", out.String())
+
+	_, err = d.Exec("watch 020a")
+	assert.Error(t, err)
+}
+
+func TestSetAndPrint(t *testing.T) {
+	ch, d, out := setup()
+
+	for _, cmd := range []string{"set V3 2a", "set i 0300", "set PC 0204", "set T0 10", "set mem 0300 12 34"} {
+		_, err := d.Exec(cmd)
+		assert.NoError(t, err, cmd)
+	}
+	assert.Equal(t, uint8(0x2a), ch.Reg.V[3])
+	assert.Equal(t, uint16(0x0300), ch.Reg.I)
+	assert.Equal(t, uint16(0x0204), ch.Reg.PC)
+	assert.Equal(t, uint8(0x10), ch.Reg.T0)
+	assert.Equal(t, []uint8{0x12, 0x34}, ch.Memory[0x0300:0x0302])
+
+	d.Exec("regs")
+	assert.Contains(t, out.String(), " I:\t0300")
+
+	d.Exec("mem 0300")
+	assert.Contains(t, out.String(), "0300\t12 34 00")
+
+	d.Exec("dis 0200 2")
+	assert.Contains(t, out.String(), "0202: 2208\tCALL 0x0208")
+
+	d.Exec("screen")
+	assert.Contains(t, out.String(), " 0 |    ")
+
+	for _, cmd := range []string{"set VG 1", "set V1 100", "set X 1", "step x", "foo"} {
+		_, err := d.Exec(cmd)
+		assert.Error(t, err, cmd)
+	}
+}
+
+func TestRun(t *testing.T) {
+	ch, d, out := setup()
+
+	assert.NoError(t, d.Run(strings.NewReader("s\nhelp\nq\ns\n")))
+	assert.Equal(t, uint16(0x0202), ch.Reg.PC)
+	assert.Contains(t, out.String(), debugger.HELP)
+}
@@ -0,0 +1,328 @@
+// Package disasm turns CHIP-8 ROM into assembler listing in the emulator mnemonics
+// (the same ones ProcessCmd prints), tracing control flow to separate code from data.
+package disasm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/brus-fabrika/chip8/chip8"
+)
+
+// DATA_BYTES_PER_LINE is the max number of bytes in one db line
+const DATA_BYTES_PER_LINE = 8
+
+// labelKind defines label name prefix, the greater kind wins when address is referenced in several ways
+type labelKind int
+
+const (
+	labelData labelKind = iota + 1 // MOV I target
+	labelJump                      // JMP target
+	labelSub                       // CALL target
+)
+
+// Program is the ROM traced from its entry point (0x200)
+type Program struct {
+	Ver chip8.ChipVersion
+	Rom []uint8
+
+	mem    []uint8 // ROM placed at its load address, so addresses could be used directly
+	code   map[uint16]bool
+	labels map[uint16]labelKind
+	cov    *chip8.Coverage // runtime coverage, if applied
+}
+
+// Trace follows JMP/CALL/skip targets from the entry point and marks reachable instructions as code,
+// everything else is data. Targets of JMP, CALL and MOV I get labels.
+func Trace(rom []uint8, ver chip8.ChipVersion) *Program {
+	p := &Program{
+		Ver:    ver,
+		Rom:    rom,
+		mem:    make([]uint8, int(chip8.MEMORY_USER)+len(rom)),
+		code:   map[uint16]bool{},
+		labels: map[uint16]labelKind{},
+	}
+	copy(p.mem[chip8.MEMORY_USER:], rom)
+
+	p.trace([]uint16{chip8.MEMORY_USER})
+
+	return p
+}
+
+// trace marks instructions reachable from the queued addresses as code
+func (p *Program) trace(queue []uint16) {
+	for len(queue) > 0 {
+		adr := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+
+		for !p.code[adr] {
+			in, ok := p.decode(adr)
+			if !ok {
+				break
+			}
+			p.code[adr] = true
+			next := adr + in.Size()
+
+			switch in.Op {
+			case chip8.OpJmp:
+				p.addLabel(in.NNN, labelJump)
+				queue = append(queue, in.NNN)
+				next = adr // already marked as code, so tracing of this path stops
+			case chip8.OpCall:
+				p.addLabel(in.NNN, labelSub)
+				queue = append(queue, in.NNN)
+			case chip8.OpRet, chip8.OpExit, chip8.OpJmpV:
+				// JMPV target is not known without running the program
+				next = adr
+			case chip8.OpSeVal, chip8.OpSneVal, chip8.OpSeReg, chip8.OpSneReg, chip8.OpSkp, chip8.OpSknp:
+				queue = append(queue, next+p.size(next))
+			case chip8.OpMovI:
+				p.addLabel(in.NNN, labelData)
+			case chip8.OpMovILong:
+				p.addLabel(in.NNNN, labelData)
+			}
+
+			adr = next
+		}
+	}
+}
+
+// ApplyCoverage uses runtime coverage to tell code from data: executed instructions are traced as code
+// (i.e. JMPV targets unknown statically), bytes read as data and never executed are not code.
+// Listing lines are annotated with coverage kind then.
+func (p *Program) ApplyCoverage(cov *chip8.Coverage) {
+	p.cov = cov
+
+	var queue []uint16
+	for adr := int(chip8.MEMORY_USER); adr < len(p.mem); adr++ {
+		if cov.Instructions[adr] {
+			queue = append(queue, uint16(adr))
+		}
+	}
+	p.trace(queue)
+
+	for adr := range p.code {
+		if cov.Kind(adr) == chip8.CoverageData {
+			delete(p.code, adr)
+		}
+	}
+}
+
+// coverage returns the coverage kind of the line bytes, the most significant one
+func (p *Program) coverage(adr, next int) chip8.CoverageKind {
+	kind := chip8.CoverageUntouched
+	for ; adr < next; adr++ {
+		kind = max(kind, p.cov.Kind(uint16(adr)))
+	}
+	return kind
+}
+
+// decode returns instruction at address if it is a valid one for the platform
+func (p *Program) decode(adr uint16) (chip8.Instruction, bool) {
+	if adr < chip8.MEMORY_USER || int(adr)+1 >= len(p.mem) {
+		return chip8.Instruction{}, false
+	}
+
+	in := chip8.DecodeAt(p.mem, int(adr))
+	info := in.Info()
+	if info == nil || in.Op == chip8.OpSys || !info.Supports(p.Ver) || int(adr)+int(in.Size()) > len(p.mem) {
+		return in, false
+	}
+
+	return in, true
+}
+
+// size returns the size of instruction at address, 2 for data
+func (p *Program) size(adr uint16) uint16 {
+	if in, ok := p.decode(adr); ok {
+		return in.Size()
+	}
+	return 2
+}
+
+func (p *Program) addLabel(adr uint16, kind labelKind) {
+	if p.labels[adr] < kind {
+		p.labels[adr] = kind
+	}
+}
+
+// IsCode tells if the address is the start of traced instruction
+func (p *Program) IsCode(adr uint16) bool {
+	return p.code[adr]
+}
+
+// Line is a single listing line: instruction or data bytes, with optional label
+type Line struct {
+	Addr  uint16
+	Bytes []uint8
+	Label string
+	Text  string // instruction or db directive
+
+	Coverage chip8.CoverageKind // runtime usage, if coverage is applied
+}
+
+// Lines lays the program out. Labels are placed only on line starts,
+// references to the addresses inside other lines are left as numbers.
+func (p *Program) Lines() []Line {
+	end := len(p.mem)
+
+	// line starts
+	var starts []int
+	for adr := int(chip8.MEMORY_USER); adr < end; {
+		starts = append(starts, adr)
+
+		if p.code[uint16(adr)] {
+			in, _ := p.decode(uint16(adr))
+			adr += int(in.Size())
+			continue
+		}
+
+		// data till the next code, label, coverage kind change or line limit
+		next := adr + 1
+		for next < end && next-adr < DATA_BYTES_PER_LINE && !p.code[uint16(next)] && p.labels[uint16(next)] == 0 &&
+			(p.cov == nil || p.cov.Kind(uint16(next)) == p.cov.Kind(uint16(adr))) {
+			next++
+		}
+		adr = next
+	}
+
+	labels := map[uint16]string{}
+	for _, adr := range starts {
+		if kind := p.labels[uint16(adr)]; kind != 0 {
+			labels[uint16(adr)] = labelName(uint16(adr), kind)
+		}
+	}
+
+	lines := make([]Line, len(starts))
+	for i, adr := range starts {
+		next := end
+		if i+1 < len(starts) {
+			next = starts[i+1]
+		}
+
+		line := Line{Addr: uint16(adr), Bytes: p.mem[adr:next], Label: labels[uint16(adr)]}
+		if p.cov != nil {
+			line.Coverage = p.coverage(adr, next)
+		}
+		if p.code[uint16(adr)] {
+			in, _ := p.decode(uint16(adr))
+			line.Text = instructionText(in, labels)
+		} else {
+			line.Text = dataText(line.Bytes)
+		}
+		lines[i] = line
+	}
+
+	return lines
+}
+
+func labelName(adr uint16, kind labelKind) string {
+	switch kind {
+	case labelSub:
+		return fmt.Sprintf("sub_%04x", adr)
+	case labelJump:
+		return fmt.Sprintf("L_%04x", adr)
+	}
+	return fmt.Sprintf("data_%04x", adr)
+}
+
+// instructionText returns instruction in assembler syntax with address operand replaced by label
+func instructionText(in chip8.Instruction, labels map[uint16]string) string {
+	text := in.String()
+
+	var adr uint16
+	switch in.Op {
+	case chip8.OpJmp, chip8.OpCall, chip8.OpMovI:
+		adr = in.NNN
+	case chip8.OpMovILong:
+		adr = in.NNNN
+	default:
+		return text
+	}
+
+	if label, ok := labels[adr]; ok {
+		text = strings.Replace(text, fmt.Sprintf("0x%04x", adr), label, 1)
+	}
+
+	return text
+}
+
+func dataText(data []uint8) string {
+	items := make([]string, len(data))
+	for i, b := range data {
+		items[i] = fmt.Sprintf("0x%02x", b)
+	}
+	return "db " + strings.Join(items, ", ")
+}
+
+// ListFile writes the listing of the ROM file for the platform (chip8, schip, schip-legacy or xo)
+// into outFile, stdout if it is empty. The listing starts with the ROM name, size and platform.
+func ListFile(romFile, platform, outFile string) error {
+	ver, err := chip8.ParseChipVersion(platform)
+	if err != nil {
+		return err
+	}
+
+	rom, err := os.ReadFile(romFile)
+	if err != nil {
+		return err
+	}
+
+	out := os.Stdout
+	if outFile != "" {
+		if out, err = os.Create(outFile); err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+
+	w := bufio.NewWriter(out)
+	fmt.Fprintf(w, "; %s, %d bytes, %s\n", romFile, len(rom), ver)
+	if err := Trace(rom, ver).Write(w); err != nil {
+		return err
+	}
+
+	return w.Flush()
+}
+
+// Write prints the listing, each line is commented with its address and raw bytes,
+// and with the coverage kind if coverage is applied
+func (p *Program) Write(w io.Writer) error {
+	lines := p.Lines()
+
+	if p.cov != nil {
+		code, executed := 0, 0
+		for _, line := range lines {
+			if p.code[line.Addr] {
+				code++
+				if line.Coverage >= chip8.CoverageExecuted {
+					executed++
+				}
+			}
+		}
+		if _, err := fmt.Fprintf(w, "; coverage: %d of %d instructions executed\n", executed, code); err != nil {
+			return err
+		}
+	}
+
+	for _, line := range lines {
+		if line.Label != "" {
+			if _, err := fmt.Fprintf(w, "%s:\n", line.Label); err != nil {
+				return err
+			}
+		}
+
+		comment := fmt.Sprintf("%04x: %x", line.Addr, line.Bytes)
+		if p.cov != nil {
+			comment = fmt.Sprintf("%-20s %s", comment, line.Coverage)
+		}
+		if _, err := fmt.Fprintf(w, "\t%-32s; %s\n", line.Text, comment); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,139 @@
+package disasm_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brus-fabrika/chip8/chip8"
+	"github.com/brus-fabrika/chip8/disasm"
+)
+
+// sprite drawing loop with subroutine and sprite data right after the code
+var testRom = []uint8{
+	0xa2, 0x0e, // 0200: MOV I, data_020e
+	0x22, 0x0a, // 0202: CALL sub_020a
+	0x3f, 0x00, // 0204: SE VF, 00
+	0x12, 0x04, // 0206: JMP L_0204
+	0x12, 0x00, // 0208: JMP L_0200
+	0xd1, 0x23, // 020a: DRAW 3, V1, V2
+	0x00, 0xee, // 020c: RET
+	0xff, 0x81, 0xff, // 020e: sprite
+}
+
+func TestTrace(t *testing.T) {
+	p := disasm.Trace(testRom, chip8.Chip_8)
+
+	for adr := uint16(0x0200); adr < 0x020e; adr += 2 {
+		assert.True(t, p.IsCode(adr), "%04x", adr)
+	}
+	assert.False(t, p.IsCode(0x020e))
+
+	lines := p.Lines()
+	if assert.Len(t, lines, 8) {
+		assert.Equal(t, disasm.Line{Addr: 0x0200, Bytes: []uint8{0xa2, 0x0e}, Label: "L_0200", Text: "MOV I, data_020e"}, lines[0])
+		assert.Equal(t, "CALL sub_020a", lines[1].Text)
+		assert.Equal(t, "L_0204", lines[2].Label)
+		assert.Equal(t, "JMP L_0204", lines[3].Text)
+		assert.Equal(t, "sub_020a", lines[5].Label)
+		assert.Equal(t, disasm.Line{Addr: 0x020e, Bytes: []uint8{0xff, 0x81, 0xff}, Label: "data_020e", Text: "db 0xff, 0x81, 0xff"}, lines[7])
+	}
+}
+
+func TestTracePlatform(t *testing.T) {
+	// SCHIP HIGH is data on CHIP-8
+	rom := []uint8{0x00, 0xff, 0x12, 0x00}
+
+	assert.False(t, disasm.Trace(rom, chip8.Chip_8).IsCode(0x0200))
+	assert.True(t, disasm.Trace(rom, chip8.Super_Chip_Modern).IsCode(0x0202))
+}
+
+func TestTraceSkipLong(t *testing.T) {
+	// SE V0, 00; MOVL I, 0x0208; EXIT; data
+	rom := []uint8{0x30, 0x00, 0xf0, 0x00, 0x02, 0x08, 0x00, 0xfd, 0x12, 0x34}
+	p := disasm.Trace(rom, chip8.XO_Chip)
+
+	assert.True(t, p.IsCode(0x0206)) // skipped 4 bytes long instruction
+	assert.False(t, p.IsCode(0x0208))
+
+	lines := p.Lines()
+	if assert.Len(t, lines, 4) {
+		assert.Equal(t, "MOVL I, data_0208", lines[1].Text)
+		assert.Equal(t, "db 0x12, 0x34", lines[3].Text)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var sb strings.Builder
+	assert.NoError(t, disasm.Trace(testRom, chip8.Chip_8).Write(&sb))
+
+	listing := sb.String()
+	assert.Contains(t, listing, "sub_020a:\n\tDRAW 3, V1, V2")
+	assert.Contains(t, listing, "; 020e: ff81ff\n")
+}
+
+func TestListFile(t *testing.T) {
+	dir := t.TempDir()
+	romFile := filepath.Join(dir, "test.ch8")
+	outFile := filepath.Join(dir, "test.asm")
+	assert.NoError(t, os.WriteFile(romFile, testRom, 0o644))
+
+	assert.NoError(t, disasm.ListFile(romFile, "xo", outFile))
+	listing, err := os.ReadFile(outFile)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(listing), "; "+romFile+", 17 bytes, "+chip8.XO_Chip.String()+"\n"))
+	assert.Contains(t, string(listing), "sub_020a:\n")
+
+	assert.Error(t, disasm.ListFile(romFile, "vip", outFile))
+	assert.Error(t, disasm.ListFile(filepath.Join(dir, "missing.ch8"), "chip8", outFile))
+}
+
+func TestApplyCoverage(t *testing.T) {
+	rom := []uint8{
+		0x60, 0x04, // 0200: MOV V0, 04
+		0xb2, 0x06, // 0202: JMPV 0x0206, to 020a
+		0x12, 0x04, // 0204: never executed
+		0xff, 0xff, 0xff, 0xff, // 0206: padding
+		0xa2, 0x18, // 020a: MOV I, 0x0218
+		0xf0, 0x65, // 020c: CAR V0
+		0xa2, 0x14, // 020e: MOV I, 0x0214
+		0xf0, 0x55, // 0210: CAM V0, rewrites the jump below with the same value
+		0x61, 0x00, // 0212: MOV V1, 00
+		0x12, 0x14, // 0214: JMP 0x0214
+		0x00, 0x00, // 0216: padding
+		0x12, // 0218: data
+	}
+
+	ch := chip8.Chip8{}
+	ch.Init(chip8.Chip_8)
+	ch.Coverage = &chip8.Coverage{}
+	ch.LoadRomFromData(rom)
+	for i := 0; i < 9; i++ {
+		assert.NoError(t, ch.Step())
+	}
+
+	p := disasm.Trace(rom, chip8.Chip_8)
+	assert.False(t, p.IsCode(0x020a))
+	p.ApplyCoverage(ch.Coverage)
+	assert.True(t, p.IsCode(0x020a))
+
+	kinds := map[uint16]chip8.CoverageKind{}
+	for _, line := range p.Lines() {
+		kinds[line.Addr] = line.Coverage
+	}
+	assert.Equal(t, chip8.CoverageExecuted, kinds[0x0202])
+	assert.Equal(t, chip8.CoverageUntouched, kinds[0x0204])
+	assert.Equal(t, chip8.CoverageSelfModified, kinds[0x0214])
+	assert.Equal(t, chip8.CoverageUntouched, kinds[0x0216])
+	assert.Equal(t, chip8.CoverageData, kinds[0x0218])
+
+	var sb strings.Builder
+	assert.NoError(t, p.Write(&sb))
+	listing := sb.String()
+	assert.True(t, strings.HasPrefix(listing, "; coverage: 8 of 8 instructions executed\n"), listing)
+	assert.Contains(t, listing, "\tMOV I, data_0218                ; 020a: a218           executed\n")
+	assert.Contains(t, listing, "; 0218: 12             data\n")
+}
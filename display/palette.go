@@ -0,0 +1,131 @@
+package display
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Palette is the display colors by pixel planes: none (background), plane 1, plane 2 and both of them.
+// CHIP-8 and SCHIP use only the first two, XO-CHIP draws with all four.
+type Palette struct {
+	Name   string
+	Colors [4]uint32 // RGB
+	Grid   uint32    // pixel outline
+}
+
+func (p Palette) String() string {
+	return fmt.Sprintf("%s %06x %06x %06x %06x %06x", p.Name, p.Colors[0], p.Colors[1], p.Colors[2], p.Colors[3], p.Grid)
+}
+
+// ParsePalette parses palette line: name, then background, plane 1, plane 2 and both planes colors in RGB hex,
+// and optional grid color (plane 2 one by default), i.e. "gray 000000 c0c0c0 606060 ffffff" or "gray #000000 ..."
+func ParsePalette(line string) (Palette, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 5 && len(fields) != 6 {
+		return Palette{}, fmt.Errorf("invalid palette %q, name and 4 or 5 colors expected", line)
+	}
+
+	var colors [5]uint32
+	for i, field := range fields[1:] {
+		v, err := strconv.ParseUint(strings.TrimPrefix(field, "#"), 16, 24)
+		if err != nil {
+			return Palette{}, fmt.Errorf("invalid palette %q color %q", fields[0], field)
+		}
+		colors[i] = uint32(v)
+	}
+	if len(fields) == 5 {
+		colors[4] = colors[2]
+	}
+
+	return Palette{Name: strings.ToLower(fields[0]), Colors: [4]uint32(colors[:4]), Grid: colors[4]}, nil
+}
+
+// Palettes is the palettes list with the current one
+type Palettes struct {
+	List    []Palette
+	Current int
+}
+
+// Palette returns the current palette
+func (ps *Palettes) Palette() *Palette {
+	return &ps.List[ps.Current]
+}
+
+// Next makes the next palette current, the first one goes after the last
+func (ps *Palettes) Next() {
+	ps.Current = (ps.Current + 1) % len(ps.List)
+}
+
+// Select makes the named palette current
+func (ps *Palettes) Select(name string) error {
+	for i, p := range ps.List {
+		if p.Name == strings.ToLower(name) {
+			ps.Current = i
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown palette %q", name)
+}
+
+// Add adds the palette or replaces the one with the same name
+func (ps *Palettes) Add(p Palette) {
+	for i := range ps.List {
+		if ps.List[i].Name == p.Name {
+			ps.List[i] = p
+			return
+		}
+	}
+	ps.List = append(ps.List, p)
+}
+
+// Read adds palettes from the palette file and selects the last one.
+// Each line is a palette as ParsePalette takes it or just a name of the palette to select,
+// lines started with # are comments.
+func (ps *Palettes) Read(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+
+		var err error
+		switch len(fields) {
+		case 0:
+			continue
+		case 1:
+			err = ps.Select(fields[0])
+		default:
+			var p Palette
+			if p, err = ParsePalette(line); err == nil {
+				ps.Add(p)
+				err = ps.Select(p.Name)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %w", n, err)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// LoadFile reads the palette file, i.e. "game.ch8.palette" next to the ROM
+func (ps *Palettes) LoadFile(fileName string) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := ps.Read(file); err != nil {
+		return fmt.Errorf("%s: %w", fileName, err)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package display_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brus-fabrika/chip8/display"
+)
+
+func TestParsePalette(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		palette display.Palette
+		err     bool
+	}{
+		{"Default grid", "Gray 000000 c0c0c0 606060 ffffff", display.Palette{Name: "gray", Colors: [4]uint32{0x000000, 0xc0c0c0, 0x606060, 0xffffff}, Grid: 0x606060}, false},
+		{"Grid", "gray 000000 c0c0c0 606060 ffffff 202020", display.Palette{Name: "gray", Colors: [4]uint32{0x000000, 0xc0c0c0, 0x606060, 0xffffff}, Grid: 0x202020}, false},
+		{"Hash colors", "gray #000000 #c0c0c0 #606060 #ffffff", display.Palette{Name: "gray", Colors: [4]uint32{0x000000, 0xc0c0c0, 0x606060, 0xffffff}, Grid: 0x606060}, false},
+		{"Few colors", "gray 000000 c0c0c0 606060", display.Palette{}, true},
+		{"Many colors", "gray 000000 c0c0c0 606060 ffffff 202020 101010", display.Palette{}, true},
+		{"Invalid color", "gray 000000 c0c0c0 606060 fffffff", display.Palette{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := display.ParsePalette(tt.line)
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.palette, p)
+		})
+	}
+}
+
+func newPalettes() *display.Palettes {
+	return &display.Palettes{List: []display.Palette{
+		{Name: "classic", Colors: [4]uint32{0x000000, 0x00c800, 0x006400, 0x646400}, Grid: 0x006400},
+		{Name: "amber", Colors: [4]uint32{0x1a0f00, 0xffb000, 0x805800, 0xffd680}, Grid: 0x3a2400},
+	}}
+}
+
+func TestPalettesRead(t *testing.T) {
+	ps := newPalettes()
+	file := `# my palettes
+gray #000000 #c0c0c0 #606060 #ffffff
+  # indented comment
+amber 000000 ffb000 805800 ffd680
+
+classic
+`
+	assert.NoError(t, ps.Read(strings.NewReader(file)))
+	assert.Len(t, ps.List, 3)
+	assert.Equal(t, 0, ps.Current)
+	assert.Equal(t, display.Palette{Name: "gray", Colors: [4]uint32{0x000000, 0xc0c0c0, 0x606060, 0xffffff}, Grid: 0x606060}, ps.List[2])
+	// same name palette is replaced
+	assert.Equal(t, uint32(0x000000), ps.List[1].Colors[0])
+
+	// the last palette is selected
+	assert.NoError(t, ps.Read(strings.NewReader("gray")))
+	assert.Equal(t, "gray", ps.Palette().Name)
+
+	ps.Next()
+	assert.Equal(t, "classic", ps.Palette().Name)
+}
+
+func TestPalettesReadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		err  string
+	}{
+		{"Unknown name", "classic\nmissing\n", "line 2: unknown palette \"missing\""},
+		{"Invalid palette", "gray 000000 c0c0c0\n", "line 1: invalid palette"},
+		{"Inline comment", "gray 000000 c0c0c0 606060 ffffff # comment\n", "line 1: invalid palette"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newPalettes().Read(strings.NewReader(tt.file))
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
+func TestPalettesLoadFile(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "game.ch8.palette")
+	assert.NoError(t, os.WriteFile(fileName, []byte("amber\nmissing\n"), 0o644))
+
+	ps := newPalettes()
+	assert.ErrorContains(t, ps.LoadFile(fileName), fileName+": line 2:")
+	assert.Equal(t, "amber", ps.Palette().Name)
+
+	assert.ErrorIs(t, ps.LoadFile(fileName+".missing"), os.ErrNotExist)
+}
@@ -0,0 +1,76 @@
+// Package display keeps the display palettes and the recent display frames to hide XOR sprite flicker,
+// as the phosphor of old CRT screens did.
+package display
+
+import (
+	"github.com/brus-fabrika/chip8/chip8"
+	"github.com/brus-fabrika/chip8/internal/enum"
+)
+
+const DEFAULT_FRAMES = 4
+
+type Mode int
+
+const (
+	Off   Mode = iota // raw display buffer
+	Decay             // pixel fades out in Frames frames after it was switched off
+	Max               // pixel is lit if it was lit in any of the last Frames frames
+)
+
+var modeNames = enum.Names[Mode]{Type: "Mode", Desc: "persistence mode", Names: []string{"off", "decay", "max"}}
+
+func (m Mode) String() string {
+	return modeNames.String(m)
+}
+
+// ParseMode parses persistence mode name: off, decay or max
+func ParseMode(name string) (Mode, error) {
+	return modeNames.Parse(name)
+}
+
+// Persistence remembers when each pixel was lit last time and with which planes,
+// Pixel tells how it should be shown now
+type Persistence struct {
+	Mode   Mode
+	Frames int // frames to fade out in (Decay) or to keep the pixel lit for (Max)
+
+	frame   uint32
+	lastLit [chip8.DISPLAY_WIDTH * chip8.DISPLAY_HEIGHT]uint32 // frame the pixel was lit last time
+	planes  [chip8.DISPLAY_WIDTH * chip8.DISPLAY_HEIGHT]uint8  // planes of the pixel when it was lit
+}
+
+func NewPersistence(mode Mode, frames int) *Persistence {
+	return &Persistence{Mode: mode, Frames: frames}
+}
+
+// Update records the display buffer of the next frame
+func (p *Persistence) Update(buf *[chip8.DISPLAY_WIDTH * chip8.DISPLAY_HEIGHT]uint8) {
+	p.frame++
+	for i, v := range buf {
+		if v != 0 {
+			p.lastLit[i] = p.frame
+			p.planes[i] = v
+		}
+	}
+}
+
+// Pixel returns planes and brightness (0..1) of the pixel at x + y*DISPLAY_WIDTH to show in the current frame
+func (p *Persistence) Pixel(i int) (uint8, float32) {
+	if p.planes[i] == 0 {
+		return 0, 0
+	}
+
+	frames := uint32(max(p.Frames, 1))
+	age := p.frame - p.lastLit[i]
+
+	switch {
+	case age == 0:
+		return p.planes[i], 1
+	case p.Mode == Max && age < frames:
+		return p.planes[i], 1
+	case p.Mode == Decay && age < frames:
+		return p.planes[i], 1 - float32(age)/float32(frames)
+	}
+
+	return 0, 0
+}
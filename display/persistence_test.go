@@ -0,0 +1,66 @@
+package display_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brus-fabrika/chip8/chip8"
+	"github.com/brus-fabrika/chip8/display"
+)
+
+func TestPersistence(t *testing.T) {
+	// pixel is drawn in the first frame and erased by XOR in the next ones
+	tests := []struct {
+		name  string
+		mode  display.Mode
+		level []float32
+	}{
+		{"Off", display.Off, []float32{1, 0, 0, 0, 0}},
+		{"Decay", display.Decay, []float32{1, 0.75, 0.5, 0.25, 0}},
+		{"Max", display.Max, []float32{1, 1, 1, 1, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := display.NewPersistence(tt.mode, 4)
+			var buf [chip8.DISPLAY_WIDTH * chip8.DISPLAY_HEIGHT]uint8
+
+			planes, level := p.Pixel(5)
+			assert.Equal(t, uint8(0), planes)
+			assert.Zero(t, level)
+
+			buf[5] = 0x02
+			for frame, want := range tt.level {
+				p.Update(&buf)
+				buf[5] = 0
+
+				planes, level := p.Pixel(5)
+				assert.InDelta(t, want, level, 1e-6, "frame %d", frame)
+				if want > 0 {
+					assert.Equal(t, uint8(0x02), planes, "frame %d", frame)
+				}
+				// other pixels are never lit
+				_, level = p.Pixel(6)
+				assert.Zero(t, level)
+			}
+		})
+	}
+}
+
+func TestPersistenceRelit(t *testing.T) {
+	p := display.NewPersistence(display.Decay, 4)
+	var buf [chip8.DISPLAY_WIDTH * chip8.DISPLAY_HEIGHT]uint8
+
+	buf[0] = 0x01
+	p.Update(&buf)
+	buf[0] = 0
+	p.Update(&buf)
+
+	// flickering sprite is lit again at full brightness with the new planes
+	buf[0] = 0x03
+	p.Update(&buf)
+	planes, level := p.Pixel(0)
+	assert.Equal(t, uint8(0x03), planes)
+	assert.Equal(t, float32(1), level)
+}
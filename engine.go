@@ -1,12 +1,38 @@
 package main
 
 import (
+	"encoding/binary"
+
 	"github.com/veandco/go-sdl2/sdl"
+
+	"github.com/brus-fabrika/chip8/chip8"
+	"github.com/brus-fabrika/chip8/display"
 )
 
+// window is switched to fullscreen and back by F11
+var fullscreen = false
+
+// recent frames are kept on the screen to hide sprite flicker, F4 switches the mode
+var persistence = display.NewPersistence(display.Off, display.DEFAULT_FRAMES)
+
 type Engine struct {
 	Window   *sdl.Window
 	Renderer *sdl.Renderer
+	Texture  *sdl.Texture // display buffer, scaled by the renderer
+
+	Scale        int32 // initial window pixels per hires display pixel, lores pixels are twice bigger
+	IntegerScale bool  // scale the display by whole factors only, otherwise fit the window keeping the aspect
+
+	fullscreen bool       // applied fullscreen state
+	panel      bool       // debug panel is shown, the window is wider for it
+	grid       []sdl.Rect // pixel outlines, kept between frames
+}
+
+// NewEngine returns the engine with default window settings, call Init to open the window
+func NewEngine() *Engine {
+	return &Engine{
+		Scale: SCREEN_SCALE,
+	}
 }
 
 func (e *Engine) Init() error {
@@ -15,11 +41,12 @@ func (e *Engine) Init() error {
 	}
 
 	w, err := sdl.CreateWindow("SDL2 Test Window", sdl.WINDOWPOS_UNDEFINED, sdl.WINDOWPOS_UNDEFINED,
-		SCREEN_WIDTH, SCREEN_HEIGHT, sdl.WINDOW_SHOWN|sdl.WINDOW_OPENGL)
+		chip8.DISPLAY_WIDTH*e.Scale, chip8.DISPLAY_HEIGHT*e.Scale, sdl.WINDOW_SHOWN|sdl.WINDOW_OPENGL|sdl.WINDOW_RESIZABLE)
 	if err != nil {
 		return err
 	}
 	e.Window = w
+	e.Window.SetMinimumSize(chip8.DISPLAY_WIDTH, chip8.DISPLAY_HEIGHT)
 
 	r, err := sdl.CreateRenderer(e.Window, -1, sdl.RENDERER_ACCELERATED)
 	if err != nil {
@@ -27,12 +54,21 @@ func (e *Engine) Init() error {
 	}
 	e.Renderer = r
 
+	t, err := e.Renderer.CreateTexture(sdl.PIXELFORMAT_ARGB8888, sdl.TEXTUREACCESS_STREAMING, chip8.DISPLAY_WIDTH, chip8.DISPLAY_HEIGHT)
+	if err != nil {
+		return err
+	}
+	e.Texture = t
+
 	return nil
 }
 
 func (e *Engine) Destroy() {
 	println("Destroying...")
 
+	if e.Texture != nil {
+		e.Texture.Destroy()
+	}
 	if e.Renderer != nil {
 		e.Renderer.Destroy()
 	}
@@ -42,3 +78,104 @@ func (e *Engine) Destroy() {
 
 	sdl.Quit()
 }
+
+// UpdateWindow applies fullscreen and debug panel toggles
+func (e *Engine) UpdateWindow() {
+	if fullscreen != e.fullscreen {
+		var flags uint32
+		if fullscreen {
+			flags = sdl.WINDOW_FULLSCREEN_DESKTOP
+		}
+		e.Window.SetFullscreen(flags)
+		e.fullscreen = fullscreen
+	}
+
+	// window grows for the panel, so the display keeps its size
+	if showOverlay != e.panel {
+		e.panel = showOverlay
+		if !e.fullscreen {
+			w, h := e.Window.GetSize()
+			if e.panel {
+				w += PANEL_WIDTH
+			} else {
+				w -= PANEL_WIDTH
+			}
+			e.Window.SetSize(w, h)
+		}
+	}
+}
+
+// ScreenRect returns where the display is drawn in the area of the given size, centered with black bars around
+func (e *Engine) ScreenRect(w, h int32) sdl.Rect {
+	var dw, dh int32
+	if k := min(w/chip8.DISPLAY_WIDTH, h/chip8.DISPLAY_HEIGHT); e.IntegerScale && k > 0 {
+		dw, dh = chip8.DISPLAY_WIDTH*k, chip8.DISPLAY_HEIGHT*k
+	} else {
+		dw, dh = w, w*chip8.DISPLAY_HEIGHT/chip8.DISPLAY_WIDTH
+		if dh > h {
+			dw, dh = h*chip8.DISPLAY_WIDTH/chip8.DISPLAY_HEIGHT, h
+		}
+	}
+
+	return sdl.Rect{X: (w - dw) / 2, Y: (h - dh) / 2, W: dw, H: dh}
+}
+
+// UpdateTexture uploads the display buffer into the texture, pixel colors are picked by the planes set.
+// Pixels kept by persistence are faded towards the background color.
+func (e *Engine) UpdateTexture(chip *chip8.Chip8) error {
+	palette := palettes.Palette().Colors
+	persistence.Update(&chip.DisplayBuffer)
+
+	pixels, pitch, err := e.Texture.Lock(nil)
+	if err != nil {
+		return err
+	}
+	defer e.Texture.Unlock()
+
+	for y := 0; y < chip8.DISPLAY_HEIGHT; y++ {
+		row := pixels[y*pitch:]
+		for x := 0; x < chip8.DISPLAY_WIDTH; x++ {
+			planes, level := persistence.Pixel(x + y*chip8.DISPLAY_WIDTH)
+			color := palette[planes&0x03]
+			if level < 1 {
+				color = fade(palette[0], color, level)
+			}
+			binary.NativeEndian.PutUint32(row[x*4:], 0xFF000000|color)
+		}
+	}
+
+	return nil
+}
+
+// fade mixes RGB colors, level 0 gives bg and 1 gives fg
+func fade(bg, fg uint32, level float32) uint32 {
+	var color uint32
+	for shift := 0; shift < 24; shift += 8 {
+		b, f := float32(bg>>shift&0xFF), float32(fg>>shift&0xFF)
+		color |= uint32(b+(f-b)*level) << shift
+	}
+	return color
+}
+
+// DrawGrid outlines lit pixels of the display drawn in the screen rect, lores pixels are outlined as 2x2 blocks
+func (e *Engine) DrawGrid(chip *chip8.Chip8, screen sdl.Rect) {
+	cell := 1
+	if !chip.Hires {
+		cell = 2
+	}
+
+	e.grid = e.grid[:0]
+	for y := 0; y < chip8.DISPLAY_HEIGHT; y += cell {
+		for x := 0; x < chip8.DISPLAY_WIDTH; x += cell {
+			if chip.DisplayBuffer[x+y*chip8.DISPLAY_WIDTH] == 0 {
+				continue
+			}
+			x0, x1 := screen.X+int32(x)*screen.W/chip8.DISPLAY_WIDTH, screen.X+int32(x+cell)*screen.W/chip8.DISPLAY_WIDTH
+			y0, y1 := screen.Y+int32(y)*screen.H/chip8.DISPLAY_HEIGHT, screen.Y+int32(y+cell)*screen.H/chip8.DISPLAY_HEIGHT
+			e.grid = append(e.grid, sdl.Rect{X: x0, Y: y0, W: x1 - x0, H: y1 - y0})
+		}
+	}
+
+	setColor(e, palettes.Palette().Grid)
+	e.Renderer.DrawRects(e.grid)
+}
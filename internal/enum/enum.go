@@ -0,0 +1,31 @@
+// Package enum names the values of small int enums, value is the index of its name.
+package enum
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Names are the value names of the enum type
+type Names[T ~int] struct {
+	Type  string   // Go type name, unknown values are printed as Type(n)
+	Desc  string   // what the values are, for parsing errors
+	Names []string // lower case names by value
+}
+
+func (n Names[T]) String(v T) string {
+	if v >= 0 && int(v) < len(n.Names) {
+		return n.Names[v]
+	}
+	return fmt.Sprintf("%s(%d)", n.Type, int(v))
+}
+
+// Parse returns the value of the name, case insensitive
+func (n Names[T]) Parse(name string) (T, error) {
+	for i, s := range n.Names {
+		if s == strings.ToLower(name) {
+			return T(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown %s %q", n.Desc, name)
+}
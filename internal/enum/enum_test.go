@@ -0,0 +1,31 @@
+package enum_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brus-fabrika/chip8/internal/enum"
+)
+
+type color int
+
+var colorNames = enum.Names[color]{Type: "color", Desc: "color", Names: []string{"red", "green", "blue"}}
+
+func TestNames(t *testing.T) {
+	for v := range colorNames.Names {
+		parsed, err := colorNames.Parse(colorNames.String(color(v)))
+		assert.NoError(t, err)
+		assert.Equal(t, color(v), parsed)
+	}
+
+	parsed, err := colorNames.Parse("Blue")
+	assert.NoError(t, err)
+	assert.Equal(t, color(2), parsed)
+
+	_, err = colorNames.Parse("pink")
+	assert.EqualError(t, err, `unknown color "pink"`)
+
+	assert.Equal(t, "color(3)", colorNames.String(3))
+	assert.Equal(t, "color(-1)", colorNames.String(-1))
+}
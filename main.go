@@ -1,148 +1,418 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"math"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
 
 	"github.com/veandco/go-sdl2/sdl"
 
+	"github.com/brus-fabrika/chip8/audio"
 	"github.com/brus-fabrika/chip8/chip8"
+	"github.com/brus-fabrika/chip8/debugger"
+	"github.com/brus-fabrika/chip8/disasm"
+	"github.com/brus-fabrika/chip8/display"
+	"github.com/brus-fabrika/chip8/rewind"
 )
 
 const (
-	SCREEN_WIDTH         = 640
-	SCREEN_HEIGHT        = 320
-	SCREEN_FG_COLOR      = 0x00C800
-	SCREEN_FG_COLOR2     = 0xC80000
-	SCREEN_BG_COLOR      = 0x0
-	USE_FIXED_FRAMERATE  = true
-	FRAMERATE            = 60
-	INSTRUCTIONS_PER_SEC = 500
+	SCREEN_SCALE     = 5 // 640x320 window
+	SCREEN_FG_COLOR  = 0x00C800
+	SCREEN_FG_COLOR2 = 0xC80000
+	SCREEN_BG_COLOR  = 0x0
 )
 
-//var displayTest = []uint8{0xa2, 0x0a, 0x61, 0x00, 0x62, 0x0a, 0xd1, 0x25, 0x12, 0x08, 0xf0, 0x90, 0xf0, 0x90, 0xf0, 0x00}
+// ROM being run, save states and flags are kept next to it
+var romFile string
 
-// flags are OK
-//var romFile = ".\\bin\\4-flags.ch8"
+// save state slot, F5 saves and F9 loads it, F6/F7 select previous/next one
+var stateSlot = 0
 
-// corax is OK
-//var romFile = ".\\bin\\3-corax+.ch8"
+const STATE_SLOTS = 10
 
-// quirks for Chip_8 are OK except display wait
-//var romFile = ".\\bin\\5-quirks.ch8"
+const REWIND_SECONDS = 180
 
-//var romFile = ".\\bin\\6-keypad.ch8"
-
-var romFile = ".\\bin\\tetris.ch8"
+// game runs backwards while BACKSPACE is held, the machine is paused meanwhile
+// and the player pause is kept aside to be restored after the rewind
+var rewinding = false
+var rewindPaused = false
 
 func main() {
-	e := Engine{}
+	if len(os.Args) < 2 || os.Args[1] == "help" || os.Args[1] == "-h" || os.Args[1] == "-help" || os.Args[1] == "--help" {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, args := findCommand(os.Args[1:])
+	if err := cmd.Run(args); err != nil {
+		fmt.Fprintf(os.Stderr, "chip8 %s: %v\n", cmd.Name, err)
+		os.Exit(1)
+	}
+}
+
+// RunCommand runs the ROM in the window or in the terminal debugger
+func RunCommand(args []string) error {
+	e := NewEngine()
+
+	fs := newFlagSet("run")
+	var m machineFlags
+	m.register(fs)
+	scale := fs.Int("scale", SCREEN_SCALE, "initial window pixels per hires display pixel, lores pixels are twice bigger")
+	scaling := fs.String("scaling", "aspect", "display scaling in the window: aspect (fit keeping the aspect) or integer (whole factors only)")
+	fs.BoolVar(&fullscreen, "fullscreen", false, "start in fullscreen, F11 toggles it")
+	palette := fs.String("palette", "", "display palette: classic, green, amber, lcd, colorblind, contrast, octo or one from palette files")
+	paletteFile := fs.String("palettes", "", "file with palettes, each line is: name background plane1 plane2 both [grid] in RGB hex")
+	var fg, fg2, bg colorFlag
+	fs.Var(&fg, "fg", "pixel color, RGB hex, overrides the palette one")
+	fs.Var(&fg2, "fg2", "XO-CHIP plane 2 pixel color, RGB hex, overrides the palette one")
+	fs.Var(&bg, "bg", "background color, RGB hex, overrides the palette one")
+	fs.BoolVar(&showGrid, "grid", false, "outline pixels with the palette grid color, F3 toggles it")
+	persistMode := fs.String("persistence", "off", "sprite flicker reduction: off, decay (pixels fade out) or max (pixels lit in any of the last frames), F4 switches it")
+	fs.IntVar(&persistence.Frames, "persistence-frames", display.DEFAULT_FRAMES, "frames to fade pixels out in or to keep them lit for")
+	waveform := fs.String("waveform", "square", "sound timer tone waveform: square, sine or triangle")
+	frequency := fs.Float64("tone", audio.DEFAULT_FREQUENCY, "sound timer tone frequency, Hz")
+	volume := fs.Float64("volume", audio.DEFAULT_VOLUME, "sound volume, 0..1")
+	fs.BoolVar(&muted, "mute", false, "start with the sound off, M toggles it")
+	debug := fs.Bool("debug", false, "run terminal debugger instead of the window")
+	traceFile := fs.String("trace", "", "trace executed instructions into JSON Lines file, - for text on stdout")
+	traceRange := fs.String("trace-range", "", "trace only addresses in range, i.e. 0200-02ff")
+	traceClass := fs.String("trace-class", "", "trace only instruction classes: flow, skip, alu, memory, display, timer, key")
+	watch := fs.String("watch", "", "comma separated memory watchpoints access:from[-to], i.e. w:0ea0-0ecf,rw:0300")
+	profileFile := fs.String("profile", "", "write pprof profile of executed instructions into file at exit")
+	coverageFile := fs.String("coverage", "", "write ROM listing annotated with executed, data and self-modified addresses at exit")
+	romFile = parseArgs(fs, args)
+
+	if *scale <= 0 {
+		return fmt.Errorf("invalid scale %d", *scale)
+	}
+	e.Scale = int32(*scale)
+
+	// palette is taken from the palettes file, then from <rom>.palette file next to the ROM and the flags
+	if *paletteFile != "" {
+		if err := palettes.LoadFile(*paletteFile); err != nil {
+			return err
+		}
+	}
+	if err := palettes.LoadFile(romFile + ".palette"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if *palette != "" {
+		if err := palettes.Select(*palette); err != nil {
+			return err
+		}
+	}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "fg":
+			palettes.Palette().Colors[1] = uint32(fg)
+		case "fg2":
+			palettes.Palette().Colors[2] = uint32(fg2)
+		case "bg":
+			palettes.Palette().Colors[0] = uint32(bg)
+		}
+	})
+
+	mode, err := display.ParseMode(*persistMode)
+	if err != nil {
+		return err
+	}
+	persistence.Mode = mode
+	if persistence.Frames <= 0 {
+		return fmt.Errorf("invalid persistence frames %d", persistence.Frames)
+	}
+
+	switch *scaling {
+	case "aspect":
+		e.IntegerScale = false
+	case "integer":
+		e.IntegerScale = true
+	default:
+		return fmt.Errorf("invalid scaling %q", *scaling)
+	}
+
+	tone := audio.NewGenerator()
+	if tone.Waveform, err = audio.ParseWaveform(*waveform); err != nil {
+		return err
+	}
+	if *frequency <= 0 || *volume < 0 || *volume > 1 {
+		return fmt.Errorf("invalid tone %g Hz at volume %g", *frequency, *volume)
+	}
+	tone.Frequency, tone.Volume = *frequency, *volume
+
+	chip, err := m.newChip(romFile)
+	if err != nil {
+		return err
+	}
+	// pause on faulty instruction, so the screen and state could be inspected
+	chip.OnError = chip8.PolicyTrap
+	chip.OnTrap = func(err *chip8.ExecError) {
+		fmt.Println("Paused on error:", err)
+		chip.RegistryDump()
+	}
+	// SCHIP user flags are kept between runs next to the ROM
+	if err := chip.SetFlagsStore(chip8.FlagsFile(romFile + ".flags")); err != nil {
+		fmt.Println("Can't load flags:", err)
+	}
+
+	if *traceFile != "" {
+		tracer, err := NewTracer(*traceFile, *traceRange, *traceClass)
+		if err != nil {
+			return fmt.Errorf("can't trace: %w", err)
+		}
+		defer tracer.Close()
+		chip.Tracer = tracer
+	}
+	if *watch != "" {
+		for _, spec := range strings.Split(*watch, ",") {
+			wp, err := chip8.ParseWatchpoint(spec)
+			if err != nil {
+				return fmt.Errorf("can't watch: %w", err)
+			}
+			chip.Watchpoints = append(chip.Watchpoints, wp)
+		}
+	}
+	// watchpoint pauses the machine, SPACE continues
+	chip.OnWatch = func(hit *chip8.WatchHit) {
+		fmt.Println("Paused on", hit)
+		chip.RegistryDump()
+	}
+	if *profileFile != "" {
+		chip.Profiler = chip8.NewProfiler()
+		chip.Profiler.InstructionsPerSec = m.IPF * chip8.FRAME_RATE
+		defer WriteProfile(chip.Profiler, *profileFile)
+	}
+	if *coverageFile != "" {
+		chip.Coverage = &chip8.Coverage{}
+		defer WriteCoverage(chip, romFile, *coverageFile)
+	}
+
+	if *debug {
+		RunDebugger(chip)
+		return nil
+	}
 
 	if err := e.Init(); err != nil {
 		e.Destroy()
-		panic(err)
+		return err
 	}
 	defer e.Destroy()
 
 	e.Window.SetTitle(romFile)
 
-	println("Hello from CHIP8")
-	println("Memory layout:")
-	fmt.Printf("\tUser memory      : 0x%x - 0x%x\n", chip8.MEMORY_USER, chip8.MEMORY_STACK-1)
-	fmt.Printf("\tStack area       : 0x%x - 0x%0x\n", chip8.MEMORY_STACK, chip8.MEMORY_INT_AREA-1)
-	fmt.Printf("\tInterpreter  area: 0x%x - 0x%0x\n", chip8.MEMORY_INT_AREA, chip8.MEMORY_REG_AREA-1)
-	fmt.Printf("\tRegisters        : 0x%x - 0x%0x\n", chip8.MEMORY_REG_AREA, chip8.MEMORY_DISPLAY-1)
-	fmt.Printf("\tUser memory start: 0x%x - 0x%0x\n", chip8.MEMORY_DISPLAY, chip8.MEMORY_SIZE-1)
-	chip := chip8.Chip8{}
-	chip.Init(chip8.Chip_8)
-	//chip.LoadRomFromFile(".\\bin\\IbmLogo.ch8")
-	chip.LoadRomFromFile(romFile)
-	//chip.LoadRomFromData(displayTest)
-	chip.MemoryDump(0x0200, 0x0600)
-	//chip.Execute()
-	//chip.DisplayDump()
-
-	perfFreq := float64(sdl.GetPerformanceFrequency())
-
-	for chip.State.Running {
-		// clear the current keyboard state
-		//chip.ClearKeyboard()
+	// the game runs silently if there is no audio device
+	sink, err := NewSDLSink(tone.SampleRate)
+	if err != nil {
+		fmt.Println("Can't open audio:", err)
+	} else {
+		defer sink.Close()
+	}
 
-		HandleEvent(&chip)
+	history := rewind.New(REWIND_SECONDS * chip8.FRAME_RATE)
+	chip.OnFrame = func() {
+		HandleEvent(chip)
 
-		if !chip.State.Running {
-			// if running disabled - exit processing cycle
-			break
+		if rewinding {
+			// no instructions run between the restored frames
+			if err := history.Back(chip); err != nil {
+				fmt.Println("Can't rewind:", err)
+			}
+			chip.State.Paused = true
+		} else if !chip.State.Paused {
+			if err := history.Push(chip); err != nil {
+				fmt.Println("Can't keep rewind history:", err)
+			}
 		}
 
-		if chip.State.Paused {
-			// we still need to make a delay, otherwise huge cpu consumption in PollEvent
-			sdl.Delay(50)
-			continue
+		if sink != nil {
+			// tone sounds while the sound timer counts down
+			tone.Muted = muted
+			if err := tone.Frame(sink, chip.Reg.T1 > 0 && !chip.State.Paused); err != nil {
+				fmt.Println("Can't play sound:", err)
+			}
 		}
 
-		start := sdl.GetPerformanceCounter()
+		UpdateDisplay(e, chip)
+	}
+
+	clock := chip8.NewRealtimeClock()
+	defer clock.Stop()
 
-		for i := 0; i < int(INSTRUCTIONS_PER_SEC/FRAMERATE); i++ {
-			cmd := uint16(chip.Memory[int(chip.Reg.PC)])<<8 + uint16(chip.Memory[int(chip.Reg.PC+1)])
-			chip.ProcessCmd(cmd)
-		}
+	if err := chip.Run(context.Background(), clock); err != nil {
+		fmt.Println("Stopped on error:", err)
+	}
 
-		end := sdl.GetPerformanceCounter()
-		elapsed := float64(end-start) / perfFreq * 1000.0
+	return nil
+}
 
-		if USE_FIXED_FRAMERATE {
-			if 1000.0/FRAMERATE > elapsed {
-				sdl.Delay(uint32(math.Floor(1000.0/FRAMERATE - elapsed)))
-			}
+func UpdateDisplay(e *Engine, chip *chip8.Chip8) {
+	e.UpdateWindow()
+
+	if err := e.UpdateTexture(chip); err != nil {
+		fmt.Println("Can't update display:", err)
+		return
+	}
+
+	w, h, err := e.Renderer.GetOutputSize()
+	if err != nil {
+		fmt.Println("Can't update display:", err)
+		return
+	}
+	if showOverlay {
+		w -= PANEL_WIDTH
+	}
+
+	e.Renderer.SetDrawColor(0, 0, 0, 255)
+	e.Renderer.Clear()
+
+	screen := e.ScreenRect(w, h)
+	e.Renderer.Copy(e.Texture, nil, &screen)
+	if showGrid {
+		e.DrawGrid(chip, screen)
+	}
+
+	if showOverlay {
+		UpdateOverlay(e, chip, sdl.Rect{X: w, Y: 0, W: PANEL_WIDTH, H: h})
+	}
+
+	e.Renderer.Present()
+}
+
+// WriteProfile writes pprof profile, see it with "go tool pprof -http=: file"
+func WriteProfile(profiler *chip8.Profiler, fileName string) {
+	file, err := os.Create(fileName)
+	if err == nil {
+		err = profiler.Write(file)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
 		}
+	}
+	if err != nil {
+		fmt.Println("Can't write profile:", err)
+	}
+}
+
+// WriteCoverage writes the ROM listing with runtime coverage of each line
+func WriteCoverage(chip *chip8.Chip8, romFile, fileName string) {
+	rom, err := os.ReadFile(romFile)
+	if err != nil {
+		fmt.Println("Can't write coverage:", err)
+		return
+	}
 
-		UpdateDisplay(&e, &chip)
-		UpdateTimer(&chip)
+	program := disasm.Trace(rom, chip.Ver)
+	program.ApplyCoverage(chip.Coverage)
+
+	file, err := os.Create(fileName)
+	if err == nil {
+		err = program.Write(file)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		fmt.Println("Can't write coverage:", err)
 	}
 }
 
-func UpdateTimer(chip *chip8.Chip8) {
-	if chip.Reg.T0 > 0 {
-		chip.Reg.T0--
+// tracerCloser is a tracer which should be closed at exit
+type tracerCloser interface {
+	chip8.Tracer
+	io.Closer
+}
+
+// NewTracer creates instructions tracer into the file ("-" for text on stdout) with optional address range and classes filter
+func NewTracer(fileName, addrRange, classes string) (tracerCloser, error) {
+	var tracer chip8.Tracer
+	var closer io.Closer = io.NopCloser(nil)
+
+	if fileName == "-" {
+		tracer = chip8.TextTracer{W: os.Stdout}
+	} else {
+		t, err := chip8.NewJSONLFileTracer(fileName)
+		if err != nil {
+			return nil, err
+		}
+		tracer, closer = t, t
 	}
 
-	if chip.Reg.T1 > 0 {
-		chip.Reg.T1--
+	filter := &chip8.FilterTracer{Next: tracer}
+	if addrRange != "" {
+		if _, err := fmt.Sscanf(addrRange, "%x-%x", &filter.From, &filter.To); err != nil {
+			closer.Close()
+			return nil, fmt.Errorf("invalid trace range %q", addrRange)
+		}
+	}
+	if classes != "" {
+		var err error
+		if filter.Classes, err = chip8.ParseOpClasses(classes); err != nil {
+			closer.Close()
+			return nil, err
+		}
 	}
+
+	return struct {
+		chip8.Tracer
+		io.Closer
+	}{filter, closer}, nil
 }
 
-func UpdateDisplay(e *Engine, chip *chip8.Chip8) {
-	var fg_r uint8 = (SCREEN_FG_COLOR & 0xFF0000) >> 16
-	var fg_g uint8 = (SCREEN_FG_COLOR & 0x00FF00) >> 8
-	var fg_b uint8 = (SCREEN_FG_COLOR & 0x0000FF)
-
-	var fg2_r uint8 = (SCREEN_FG_COLOR2 & 0xFF0000) >> 16
-	var fg2_g uint8 = (SCREEN_FG_COLOR2 & 0x00FF00) >> 8
-	var fg2_b uint8 = (SCREEN_FG_COLOR2 & 0x0000FF)
-
-	var bg_r uint8 = (SCREEN_BG_COLOR & 0xFF0000) >> 16
-	var bg_g uint8 = (SCREEN_BG_COLOR & 0x00FF00) >> 8
-	var bg_b uint8 = (SCREEN_BG_COLOR & 0x0000FF)
-
-	for y := 0; y < chip8.DISPLAY_HEIGHT; y++ {
-		for x := 0; x < chip8.DISPLAY_WIDTH; x++ {
-			rect := sdl.Rect{X: int32(x * 10), Y: int32(y * 10), W: 10, H: 10}
-			if chip.DisplayBuffer[x+y*chip8.DISPLAY_WIDTH] {
-				e.Renderer.SetDrawColor(fg_r, fg_g, fg_b, 255)
-				e.Renderer.FillRect(&rect)
-				e.Renderer.SetDrawColor(fg2_r, fg2_g, fg2_b, 255)
-				e.Renderer.DrawRect(&rect)
+// RunDebugger controls the machine with terminal commands, Ctrl-C interrupts continue
+func RunDebugger(chip *chip8.Chip8) {
+	// debugger reports errors and watchpoints itself
+	chip.OnTrap = nil
+	chip.OnWatch = nil
 
-			} else {
-				// we should clear the "pixel" otherwise, or it won't be re-drawn never ever
-				e.Renderer.SetDrawColor(bg_r, bg_g, bg_b, 255)
-				e.Renderer.FillRect(&rect)
-			}
+	d := debugger.New(chip, os.Stdout)
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	go func() {
+		for range interrupt {
+			d.Interrupt()
 		}
+	}()
+
+	fmt.Print(debugger.HELP)
+	if err := d.Run(os.Stdin); err != nil {
+		fmt.Println("Debugger error:", err)
 	}
+}
 
-	e.Renderer.Present()
+// stateFile is the save state slot file next to the ROM, i.e. tetris.ch8.state0
+func stateFile(slot int) string {
+	return fmt.Sprintf("%s.state%d", romFile, slot)
+}
+
+func SaveState(chip *chip8.Chip8, slot int) {
+	data, err := chip.Snapshot()
+	if err == nil {
+		err = os.WriteFile(stateFile(slot), data, 0o644)
+	}
+
+	if err != nil {
+		fmt.Println("Can't save state:", err)
+		return
+	}
+	fmt.Println("State saved to slot", slot)
+}
+
+func LoadState(chip *chip8.Chip8, slot int) {
+	data, err := os.ReadFile(stateFile(slot))
+	if err == nil {
+		err = chip.Restore(data)
+	}
+
+	if err != nil {
+		fmt.Println("Can't load state:", err)
+		return
+	}
+	fmt.Println("State loaded from slot", slot)
 }
 
 func HandleEvent(chip *chip8.Chip8) {
@@ -163,6 +433,11 @@ func HandleKeyboardEvent(chip *chip8.Chip8, event *sdl.KeyboardEvent) {
 		// but only on key up
 		//chip.ClearKeyboard()
 		switch event.Keysym.Sym {
+		case sdl.K_BACKSPACE:
+			if rewinding {
+				rewinding = false
+				chip.State.Paused = rewindPaused
+			}
 		case sdl.K_1:
 			chip.Keyboard[0x01] = false
 		case sdl.K_2:
@@ -203,11 +478,47 @@ func HandleKeyboardEvent(chip *chip8.Chip8, event *sdl.KeyboardEvent) {
 			println("Quit")
 			chip.State.Running = false
 		case sdl.K_SPACE:
-			chip.State.Paused = !chip.State.Paused
+			if rewinding {
+				rewindPaused = !rewindPaused
+			} else {
+				chip.State.Paused = !chip.State.Paused
+			}
 			//if !paused {
 			//frameCounter = 0
 			//currentFrameCounter = 0
 			//}
+		case sdl.K_BACKSPACE:
+			// held key repeats KEYDOWN
+			if !rewinding {
+				rewinding = true
+				rewindPaused = chip.State.Paused
+				chip.State.Paused = true
+			}
+		case sdl.K_F5:
+			SaveState(chip, stateSlot)
+		case sdl.K_F9:
+			LoadState(chip, stateSlot)
+		case sdl.K_F6:
+			stateSlot = (stateSlot + STATE_SLOTS - 1) % STATE_SLOTS
+			fmt.Println("State slot:", stateSlot)
+		case sdl.K_F7:
+			stateSlot = (stateSlot + 1) % STATE_SLOTS
+			fmt.Println("State slot:", stateSlot)
+		case sdl.K_F1:
+			showOverlay = !showOverlay
+		case sdl.K_F2:
+			palettes.Next()
+			fmt.Println("Palette:", palettes.Palette().Name)
+		case sdl.K_F3:
+			showGrid = !showGrid
+		case sdl.K_F4:
+			persistence.Mode = (persistence.Mode + 1) % (display.Max + 1)
+			fmt.Println("Persistence:", persistence.Mode)
+		case sdl.K_F11:
+			fullscreen = !fullscreen
+		case sdl.K_m:
+			muted = !muted
+			fmt.Println("Sound muted:", muted)
 		}
 	}
 	if event.Type == sdl.KEYDOWN {
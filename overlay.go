@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/veandco/go-sdl2/sdl"
+
+	"github.com/brus-fabrika/chip8/chip8"
+)
+
+const (
+	PANEL_WIDTH           = 320
+	PANEL_BG_COLOR        = 0x202020
+	PANEL_TEXT_COLOR      = 0xC8C8C8
+	PANEL_HIGHLIGHT_COLOR = 0xFFC800
+	PANEL_FONT_SCALE      = 2
+	PANEL_CHAR_WIDTH      = (FONT_GLYPH_WIDTH + 1) * PANEL_FONT_SCALE
+	PANEL_LINE_HEIGHT     = (FONT_GLYPH_HEIGHT + 2) * PANEL_FONT_SCALE
+	PANEL_MARGIN          = 8
+	PANEL_DIS_BEFORE      = 2 // instructions shown before PC
+	PANEL_DIS_LINES       = 7
+	PANEL_STACK_FRAMES    = 4
+)
+
+// debug panel is shown on the right of the screen, F1 toggles it
+var showOverlay = false
+
+// span is a piece of the panel line, highlighted ones are the current instruction and pressed keys
+type span struct {
+	Text      string
+	Highlight bool
+}
+
+type overlayLine []span
+
+func text(format string, args ...any) overlayLine {
+	return overlayLine{{Text: fmt.Sprintf(format, args...)}}
+}
+
+// OverlayLines lays out the panel: registers, timers, disassembly around PC, call stack and keypad
+func OverlayLines(chip *chip8.Chip8) []overlayLine {
+	reg := chip.Reg
+
+	status := "RUN"
+	switch {
+	case !chip.State.Running:
+		status = "STOP"
+	case chip.State.Paused:
+		status = "PAUSE"
+	case chip.State.WaitingForKey:
+		status = "KEY?"
+	}
+
+	lines := []overlayLine{
+		text("PC %04x  I %04x  SP %04x", reg.PC, reg.I, reg.SP),
+		text("T0 %02x  T1 %02x  %s", reg.T0, reg.T1, status),
+		text("V0-7 % x", reg.V[:8]),
+		text("V8-F % x", reg.V[8:]),
+		nil,
+	}
+
+	// instructions are 2 bytes long mostly, so the ones before PC are guessed that way
+	mem := chip.Memory[:chip.MemorySize()]
+	adr := max(int(reg.PC)-2*PANEL_DIS_BEFORE, 0)
+	for i := 0; i < PANEL_DIS_LINES && adr+1 < len(mem); i++ {
+		in := chip8.DecodeAt(mem, adr)
+		marker := " "
+		if adr == int(reg.PC) {
+			marker = ">"
+		}
+		lines = append(lines, overlayLine{{Text: fmt.Sprintf("%s%04x %04x %s", marker, adr, in.Opcode, in), Highlight: adr == int(reg.PC)}})
+		adr += int(in.Size())
+	}
+
+	frames := chip.CallStack()
+	lines = append(lines, nil, text("STACK %d/%d", len(frames), chip.StackLimit()))
+	pc := reg.PC
+	for i, frame := range frames {
+		if i == PANEL_STACK_FRAMES {
+			lines = append(lines, text(" ..."))
+			break
+		}
+		lines = append(lines, text(" #%d %04x SUB_%04x", i, pc, frame.Entry))
+		pc = frame.Caller
+	}
+	if len(frames) <= PANEL_STACK_FRAMES {
+		lines = append(lines, text(" #%d %04x MAIN", len(frames), pc))
+	}
+
+	// keypad layout of COSMAC VIP
+	lines = append(lines, nil, text("KEYS"))
+	for _, row := range []string{"123C", "456D", "789E", "A0BF"} {
+		line := overlayLine{{Text: " "}}
+		for _, key := range row {
+			k := strings.IndexRune("0123456789ABCDEF", key)
+			line = append(line, span{Text: string(key), Highlight: chip.Keyboard[k]}, span{Text: " "})
+		}
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+// UpdateOverlay draws the panel in the area, the renderer is presented by the caller
+func UpdateOverlay(e *Engine, chip *chip8.Chip8, area sdl.Rect) {
+	setColor(e, PANEL_BG_COLOR)
+	e.Renderer.FillRect(&area)
+
+	// glyph pixels are collected by color and filled at once
+	var normal, highlight []sdl.Rect
+	for row, line := range OverlayLines(chip) {
+		x := area.X + PANEL_MARGIN
+		y := area.Y + int32(PANEL_MARGIN+row*PANEL_LINE_HEIGHT)
+		if y+PANEL_LINE_HEIGHT > area.Y+area.H {
+			break
+		}
+		for _, s := range line {
+			for _, r := range strings.ToUpper(s.Text) {
+				if x+PANEL_CHAR_WIDTH > area.X+area.W {
+					break
+				}
+				rects := glyphRects(unicode.ToUpper(r), x, y)
+				if s.Highlight {
+					highlight = append(highlight, rects...)
+				} else {
+					normal = append(normal, rects...)
+				}
+				x += PANEL_CHAR_WIDTH
+			}
+		}
+	}
+
+	setColor(e, PANEL_TEXT_COLOR)
+	e.Renderer.FillRects(normal)
+	setColor(e, PANEL_HIGHLIGHT_COLOR)
+	e.Renderer.FillRects(highlight)
+}
+
+// glyphRects returns scaled pixels of the character at x, y
+func glyphRects(r rune, x, y int32) []sdl.Rect {
+	var rects []sdl.Rect
+	for gy, bits := range glyph(r) {
+		for gx := 0; gx < FONT_GLYPH_WIDTH; gx++ {
+			if bits&(1<<(FONT_GLYPH_WIDTH-1-gx)) != 0 {
+				rects = append(rects, sdl.Rect{
+					X: x + int32(gx*PANEL_FONT_SCALE), Y: y + int32(gy*PANEL_FONT_SCALE),
+					W: PANEL_FONT_SCALE, H: PANEL_FONT_SCALE,
+				})
+			}
+		}
+	}
+	return rects
+}
+
+func setColor(e *Engine, color uint32) {
+	e.Renderer.SetDrawColor(uint8(color>>16), uint8(color>>8), uint8(color), 255)
+}
@@ -0,0 +1,17 @@
+package main
+
+import "github.com/brus-fabrika/chip8/display"
+
+// palettes are the built-in ones and the ones from palette files, F2 switches to the next one
+var palettes = display.Palettes{List: []display.Palette{
+	{Name: "classic", Colors: [4]uint32{SCREEN_BG_COLOR, SCREEN_FG_COLOR, SCREEN_FG_COLOR2, 0x646400}, Grid: SCREEN_FG_COLOR2},
+	{Name: "green", Colors: [4]uint32{0x0A140A, 0x33FF66, 0x1A8033, 0xB3FFC6}, Grid: 0x0A2A12},
+	{Name: "amber", Colors: [4]uint32{0x1A0F00, 0xFFB000, 0x805800, 0xFFD680}, Grid: 0x3A2400},
+	{Name: "lcd", Colors: [4]uint32{0x9BBC0F, 0x0F380F, 0x8BAC0F, 0x306230}, Grid: 0x8BAC0F},
+	{Name: "colorblind", Colors: [4]uint32{0x000000, 0xE69F00, 0x56B4E9, 0xF0E442}, Grid: 0x333333}, // Okabe-Ito colors
+	{Name: "contrast", Colors: [4]uint32{0x000000, 0xFFFFFF, 0xFFFF00, 0x00FFFF}, Grid: 0x808080},
+	{Name: "octo", Colors: [4]uint32{0x996600, 0xFFCC00, 0xFF6600, 0x662200}, Grid: 0x996600},
+}}
+
+// pixels are outlined with the palette grid color, F3 toggles it
+var showGrid = false
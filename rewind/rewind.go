@@ -0,0 +1,139 @@
+// Package rewind keeps the history of machine states, so the game can be run backwards frame by frame.
+package rewind
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+
+	"github.com/brus-fabrika/chip8/chip8"
+)
+
+// History keeps per-frame machine states as a ring buffer of compressed deltas.
+// Delta is XOR of two consecutive snapshots, so the previous state is restored
+// from the current one by applying the same delta again, no full copies are kept.
+// Buffers and the compressor are reused, so frames are pushed without big allocations.
+type History struct {
+	frames [][]byte // compressed deltas, ring buffer
+	head   int      // next frame position
+	count  int
+
+	last  []byte // snapshot of the latest pushed frame
+	state []byte // snapshot buffer, swapped with last
+	delta []byte // XOR buffer
+
+	buf bytes.Buffer
+	w   *flate.Writer
+	r   io.ReadCloser
+	src bytes.Reader
+}
+
+func New(frames int) *History {
+	return &History{frames: make([][]byte, frames)}
+}
+
+// Len returns the number of frames Back can go
+func (h *History) Len() int {
+	return h.count
+}
+
+// Push stores the current machine state as the next frame
+func (h *History) Push(chip *chip8.Chip8) error {
+	state, err := chip.AppendSnapshot(h.state[:0])
+	if err != nil {
+		return err
+	}
+
+	if h.last != nil {
+		h.delta = xorBytes(h.delta[:0], state, h.last)
+		if err := h.compress(h.delta); err != nil {
+			return err
+		}
+
+		// the oldest frame is overwritten when the buffer is full, its memory is reused
+		h.frames[h.head] = append(h.frames[h.head][:0], h.buf.Bytes()...)
+		h.head = (h.head + 1) % len(h.frames)
+		h.count = min(h.count+1, len(h.frames))
+	}
+
+	h.last, h.state = state, h.last
+	return nil
+}
+
+// Back restores the machine to the previous frame, the oldest one is kept when the history is over.
+// The keypad is live, so it is not restored, otherwise keys held in the past would get stuck.
+func (h *History) Back(chip *chip8.Chip8) error {
+	if h.last == nil {
+		return nil
+	}
+
+	if h.count > 0 {
+		prev := (h.head + len(h.frames) - 1) % len(h.frames)
+		if err := h.decompress(h.frames[prev]); err != nil {
+			return err
+		}
+		h.head = prev
+		h.count--
+		xorBytes(h.last[:0], h.last, h.delta)
+	}
+
+	keys := chip.Keyboard
+	if err := chip.Restore(h.last); err != nil {
+		return err
+	}
+	chip.SetKeyboard(keys)
+
+	return nil
+}
+
+// Size returns memory taken by the compressed history
+func (h *History) Size() int {
+	size := 0
+	for i := 0; i < h.count; i++ {
+		size += len(h.frames[(h.head+len(h.frames)-1-i)%len(h.frames)])
+	}
+	return size
+}
+
+// xorBytes appends XOR of a and b to dst, dst may be a itself
+func xorBytes(dst, a, b []byte) []byte {
+	for i := range a {
+		dst = append(dst, a[i]^b[i])
+	}
+	return dst
+}
+
+func (h *History) compress(data []byte) error {
+	h.buf.Reset()
+	if h.w == nil {
+		w, err := flate.NewWriter(&h.buf, flate.BestSpeed)
+		if err != nil {
+			return err
+		}
+		h.w = w
+	} else {
+		h.w.Reset(&h.buf)
+	}
+
+	if _, err := h.w.Write(data); err != nil {
+		return err
+	}
+	return h.w.Close()
+}
+
+// decompress reads the delta of the last snapshot size into the XOR buffer
+func (h *History) decompress(data []byte) error {
+	h.src.Reset(data)
+	if h.r == nil {
+		h.r = flate.NewReader(&h.src)
+	} else if err := h.r.(flate.Resetter).Reset(&h.src, nil); err != nil {
+		return err
+	}
+
+	if cap(h.delta) < len(h.last) {
+		h.delta = make([]byte, len(h.last))
+	}
+	h.delta = h.delta[:len(h.last)]
+	_, err := io.ReadFull(h.r, h.delta)
+	return err
+}
@@ -0,0 +1,104 @@
+package rewind_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/brus-fabrika/chip8/chip8"
+	"github.com/brus-fabrika/chip8/rewind"
+)
+
+func newChip() *chip8.Chip8 {
+	ch := &chip8.Chip8{}
+	ch.Init(chip8.Super_Chip_Modern)
+	ch.Seed(0x1234)
+
+	// RND V1, ff; DRAW 5, V1, V1; ADD V2, 1; JMP 0x0200
+	ch.LoadRomFromData([]uint8{0xc1, 0xff, 0xd1, 0x15, 0x72, 0x01, 0x12, 0x00})
+	return ch
+}
+
+// pushFrames runs and pushes the frames, snapshots of them are returned
+func pushFrames(t *testing.T, ch *chip8.Chip8, h *rewind.History, frames int) [][]byte {
+	var snapshots [][]byte
+	for i := 0; i < frames; i++ {
+		ch.RunFrame(7)
+		assert.NoError(t, h.Push(ch))
+
+		data, err := ch.Snapshot()
+		assert.NoError(t, err)
+		snapshots = append(snapshots, data)
+	}
+	return snapshots
+}
+
+func backTo(t *testing.T, ch *chip8.Chip8, h *rewind.History, expected []byte) {
+	t.Helper()
+
+	assert.NoError(t, h.Back(ch))
+	data, err := ch.Snapshot()
+	assert.NoError(t, err)
+	assert.Equal(t, expected, data)
+}
+
+func TestPushBack(t *testing.T) {
+	ch := newChip()
+	h := rewind.New(100)
+
+	snapshots := pushFrames(t, ch, h, 10)
+	assert.Equal(t, 9, h.Len())
+	assert.Greater(t, h.Size(), 0)
+
+	for i := 8; i >= 0; i-- {
+		backTo(t, ch, h, snapshots[i])
+	}
+	assert.Equal(t, 0, h.Len())
+
+	// machine goes forward from the restored frame the same way
+	again := pushFrames(t, ch, h, 9)
+	assert.Equal(t, snapshots[1:], again)
+}
+
+func TestWrapAround(t *testing.T) {
+	ch := newChip()
+	h := rewind.New(3)
+
+	snapshots := pushFrames(t, ch, h, 6)
+	assert.Equal(t, 3, h.Len())
+
+	backTo(t, ch, h, snapshots[4])
+	backTo(t, ch, h, snapshots[3])
+	backTo(t, ch, h, snapshots[2])
+
+	// history is over - the oldest kept frame stays
+	assert.Equal(t, 0, h.Len())
+	backTo(t, ch, h, snapshots[2])
+	backTo(t, ch, h, snapshots[2])
+}
+
+func TestBackEmpty(t *testing.T) {
+	ch := newChip()
+	h := rewind.New(10)
+
+	pc := ch.Reg.PC
+	assert.NoError(t, h.Back(ch))
+	assert.Equal(t, pc, ch.Reg.PC)
+
+	snapshots := pushFrames(t, ch, h, 1)
+	assert.Equal(t, 0, h.Len())
+	backTo(t, ch, h, snapshots[0])
+}
+
+func TestBackKeepsKeyboard(t *testing.T) {
+	ch := newChip()
+	h := rewind.New(10)
+
+	ch.Keyboard[0x05] = true
+	pushFrames(t, ch, h, 3)
+
+	ch.Keyboard[0x05] = false
+	ch.Keyboard[0x0A] = true
+	assert.NoError(t, h.Back(ch))
+	assert.Equal(t, [0x10]bool{0x0A: true}, ch.Keyboard)
+}
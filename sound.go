@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/veandco/go-sdl2/sdl"
+
+	"github.com/brus-fabrika/chip8/audio"
+)
+
+// samples are dropped while the device has more than that queued, so the sound doesn't lag behind the game
+const AUDIO_MAX_QUEUED = audio.SAMPLE_RATE / 10 * 4 // 100 ms of float32 samples
+
+// sound is switched off and on by M
+var muted = false
+
+// SDLSink plays samples on the default SDL audio device
+type SDLSink struct {
+	dev sdl.AudioDeviceID
+	buf []byte
+}
+
+func NewSDLSink(sampleRate int) (*SDLSink, error) {
+	spec := sdl.AudioSpec{Freq: int32(sampleRate), Format: sdl.AUDIO_F32SYS, Channels: 1, Samples: 1024}
+	dev, err := sdl.OpenAudioDevice("", false, &spec, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	sdl.PauseAudioDevice(dev, false)
+
+	return &SDLSink{dev: dev}, nil
+}
+
+func (s *SDLSink) Write(samples []float32) error {
+	if sdl.GetQueuedAudioSize(s.dev) > AUDIO_MAX_QUEUED {
+		return nil
+	}
+
+	s.buf = s.buf[:0]
+	for _, v := range samples {
+		s.buf = binary.NativeEndian.AppendUint32(s.buf, math.Float32bits(v))
+	}
+	return sdl.QueueAudio(s.dev, s.buf)
+}
+
+func (s *SDLSink) Close() {
+	sdl.CloseAudioDevice(s.dev)
+}